@@ -0,0 +1,143 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatCommon = "common"
+
+	annotationKey = "api-play-access-log-annotation"
+)
+
+// Annotation is what a dynamic api decided for a request.
+type Annotation struct {
+	// Api is the path of the api that matched the request.
+	Api     string `json:"api"`
+	Method  string `json:"api_method"`
+	Variant string `json:"variant,omitempty"`
+	// Chosen is the entry of the config which gave the status (e.g. `statuses[1]` or `rate_limit`), it's empty when the status comes from the calls.
+	Chosen          string   `json:"chosen_status,omitempty"`
+	InjectedLatency int64    `json:"injected_latency_ms"`
+	Faults          []string `json:"faults,omitempty"`
+	Calls           []Call   `json:"calls,omitempty"`
+}
+
+type Call struct {
+	Url    string `json:"url"`
+	Status int    `json:"status"`
+	Result string `json:"result"`
+}
+
+// Annotate returns the annotation of the request, it's only logged if an access log is configured.
+func Annotate(c *gin.Context) *Annotation {
+	if v, ok := c.Get(annotationKey); ok {
+		return v.(*Annotation)
+	}
+	a := &Annotation{}
+	c.Set(annotationKey, a)
+	return a
+}
+
+type entry struct {
+	Time      time.Time `json:"time"`
+	Client    string    `json:"client"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int       `json:"bytes"`
+	Duration  int64     `json:"duration_ms"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	TraceId   string    `json:"trace_id,omitempty"`
+	*Annotation
+}
+
+// Open returns the writer for a destination, `stdout` or the path of a file which is appended to.
+func Open(dest string) (io.Writer, error) {
+	if dest == "stdout" || dest == "-" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// Middleware writes a line per request to w in the json or common log format.
+// It must be used after the observability middleware so that the trace of the request is known.
+func Middleware(w io.Writer, format string) (gin.HandlerFunc, error) {
+	if format != FormatJSON && format != FormatCommon {
+		return nil, fmt.Errorf("invalid access log format: %s valid formats: '%s' and '%s'", format, FormatJSON, FormatCommon)
+	}
+	mu := sync.Mutex{}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		e := entry{
+			Time:      start,
+			Client:    c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.RequestURI(),
+			Proto:     c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     max(c.Writer.Size(), 0),
+			Duration:  time.Since(start).Milliseconds(),
+			UserAgent: c.Request.UserAgent(),
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			e.TraceId = sc.TraceID().String()
+		}
+		if v, ok := c.Get(annotationKey); ok {
+			e.Annotation = v.(*Annotation)
+		}
+		var line []byte
+		if format == FormatJSON {
+			line, _ = json.Marshal(e)
+			line = append(line, '\n')
+		} else {
+			line = []byte(e.common())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write(line)
+	}, nil
+}
+
+// common formats the entry in the common log format followed by the annotations as key=value pairs.
+func (e entry) common() string {
+	sb := &strings.Builder{}
+	_, _ = fmt.Fprintf(sb, "%s - - [%s] \"%s %s %s\" %d %d", e.Client, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.Path, e.Proto, e.Status, e.Bytes)
+	_, _ = fmt.Fprintf(sb, " duration_ms=%d", e.Duration)
+	if e.TraceId != "" {
+		_, _ = fmt.Fprintf(sb, " trace_id=%s", e.TraceId)
+	}
+	if a := e.Annotation; a != nil {
+		_, _ = fmt.Fprintf(sb, " api=%q api_method=%s", a.Api, a.Method)
+		if a.Variant != "" {
+			_, _ = fmt.Fprintf(sb, " variant=%q", a.Variant)
+		}
+		if a.Chosen != "" {
+			_, _ = fmt.Fprintf(sb, " chosen_status=%s", a.Chosen)
+		}
+		_, _ = fmt.Fprintf(sb, " injected_latency_ms=%d", a.InjectedLatency)
+		if len(a.Faults) > 0 {
+			_, _ = fmt.Fprintf(sb, " faults=%s", strings.Join(a.Faults, ","))
+		}
+		if len(a.Calls) > 0 {
+			var calls []string
+			for _, c := range a.Calls {
+				calls = append(calls, fmt.Sprintf("%s:%d", c.Url, c.Status))
+			}
+			_, _ = fmt.Fprintf(sb, " calls=%s", strings.Join(calls, ","))
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
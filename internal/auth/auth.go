@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/x509"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lahabana/api-play/pkg/api"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Config of the authentication of mutating control endpoints, a request is allowed if any of the configured methods succeeds.
+// When no method is configured and ReadOnly is false everything is allowed.
+type Config struct {
+	// Token is a static bearer token.
+	Token string
+	// Users are basic auth users with their password.
+	Users map[string]string
+	// ClientNames are the identities of mTLS clients allowed (common name, DNS or URI SAN).
+	ClientNames []string
+	// ReadOnly rejects all mutating requests.
+	ReadOnly bool
+}
+
+func (c Config) enabled() bool {
+	return c.ReadOnly || c.Token != "" || len(c.Users) > 0 || len(c.ClientNames) > 0
+}
+
+// ReadToken reads a token from a file and trims spaces around it.
+func ReadToken(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", file)
+	}
+	return token, nil
+}
+
+// ReadUsers reads basic auth users from a file with a `user:password` entry per line.
+// Empty lines and lines starting with `#` are ignored.
+func ReadUsers(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	users := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		user, password, ok := strings.Cut(entry, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:password", file, line)
+		}
+		users[user] = password
+	}
+	return users, scanner.Err()
+}
+
+// Middleware checks the authentication of mutating requests (anything but GET, HEAD and OPTIONS).
+func Middleware(conf Config) api.MiddlewareFunc {
+	return func(c *gin.Context) {
+		if !conf.enabled() || !isMutating(c.Request.Method) {
+			return
+		}
+		if conf.ReadOnly {
+			abort(c, http.StatusForbidden, "Server is in read-only mode")
+			return
+		}
+		if conf.Token != "" {
+			if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && equal(token, conf.Token) {
+				return
+			}
+		}
+		if len(conf.Users) > 0 {
+			if user, password, ok := c.Request.BasicAuth(); ok {
+				if expected, exists := conf.Users[user]; exists && equal(password, expected) {
+					return
+				}
+			}
+		}
+		if len(conf.ClientNames) > 0 && c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+			if slices.ContainsFunc(identities(c.Request.TLS.VerifiedChains[0][0]), func(id string) bool {
+				return slices.Contains(conf.ClientNames, id)
+			}) {
+				return
+			}
+			abort(c, http.StatusForbidden, "Client certificate is not allowed")
+			return
+		}
+		if conf.Token != "" {
+			c.Header("WWW-Authenticate", `Bearer realm="api-play"`)
+		} else if len(conf.Users) > 0 {
+			c.Header("WWW-Authenticate", `Basic realm="api-play"`)
+		}
+		abort(c, http.StatusUnauthorized, "Missing or invalid credentials")
+	}
+}
+
+func isMutating(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// identities returns the common name and the DNS and URI SANs of a certificate.
+func identities(cert *x509.Certificate) []string {
+	res := []string{cert.Subject.CommonName}
+	res = append(res, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		res = append(res, u.String())
+	}
+	return res
+}
+
+func abort(c *gin.Context, status int, details string) {
+	c.AbortWithStatusJSON(status, api.ErrorResponse{Status: float32(status), Details: details})
+}
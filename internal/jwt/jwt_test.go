@@ -0,0 +1,177 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	now       = time.Unix(1_700_000_000, 0)
+	secret    = []byte("a-secret-of-at-least-32-bytes-long")
+	rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func b64JSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(b)
+}
+
+// sign builds a token with header and claims signed with key using alg, the signature doesn't depend on the header alg.
+func sign(t *testing.T, alg string, key any, header map[string]any, claims map[string]any) string {
+	t.Helper()
+	signed := b64JSON(t, header) + "." + b64JSON(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	var err error
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:], nil)
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	default:
+		t.Fatalf("unsupported alg in test: %s", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func jwks(t *testing.T) []Key {
+	t.Helper()
+	doc := fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hmac", "k": "%s"},
+		{"kty": "RSA", "kid": "rsa", "n": "%s", "e": "%s"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "%s", "y": "%s"}
+	]}`,
+		b64(secret),
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))),
+	)
+	keys, err := ParseJWKS([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestVerify(t *testing.T) {
+	keys := jwks(t)
+	valid := map[string]any{"sub": "alice", "exp": now.Unix() + 60}
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{name: "hs256", token: sign(t, "HS256", secret, map[string]any{"alg": "HS256"}, valid)},
+		{name: "rs256", token: sign(t, "RS256", rsaKey, map[string]any{"alg": "RS256", "kid": "rsa"}, valid)},
+		{name: "ps256", token: sign(t, "PS256", rsaKey, map[string]any{"alg": "PS256"}, valid)},
+		{name: "es256", token: sign(t, "ES256", ecKey, map[string]any{"alg": "ES256", "kid": "ec"}, valid)},
+		{name: "not 3 parts", token: "abc.def", err: "malformed token"},
+		{name: "header not base64", token: "!!.e30.sig", err: "invalid header"},
+		{name: "header not json", token: b64([]byte("nope")) + ".e30.", err: "invalid header"},
+		{name: "empty alg", token: b64JSON(t, map[string]any{"alg": ""}) + ".e30.", err: "unsupported alg"},
+		{name: "short alg", token: b64JSON(t, map[string]any{"alg": "X"}) + ".e30.", err: "unsupported alg"},
+		{name: "none alg", token: b64JSON(t, map[string]any{"alg": "none"}) + ".e30.", err: "unsupported alg"},
+		{name: "unknown alg", token: b64JSON(t, map[string]any{"alg": "HS999"}) + ".e30.", err: "unsupported alg"},
+		{name: "signature not base64", token: b64JSON(t, map[string]any{"alg": "HS256"}) + ".e30.!!", err: "invalid signature"},
+		{name: "hs256 with the rsa public key as secret", token: sign(t, "HS256", rsaKey.N.Bytes(), map[string]any{"alg": "HS256", "kid": "rsa"}, valid), err: "invalid signature"},
+		{name: "rsa signature declared as es256", token: sign(t, "RS256", rsaKey, map[string]any{"alg": "ES256"}, valid), err: "invalid signature"},
+		{name: "ec signature declared as rs256", token: sign(t, "ES256", ecKey, map[string]any{"alg": "RS256"}, valid), err: "invalid signature"},
+		{name: "wrong secret", token: sign(t, "HS256", []byte("other"), map[string]any{"alg": "HS256"}, valid), err: "invalid signature"},
+		{name: "kid of another key", token: sign(t, "RS256", rsaKey, map[string]any{"alg": "RS256", "kid": "ec"}, valid), err: "invalid signature"},
+		{name: "unknown kid", token: sign(t, "HS256", secret, map[string]any{"alg": "HS256", "kid": "missing"}, valid), err: "invalid signature"},
+		{name: "expired", token: sign(t, "HS256", secret, map[string]any{"alg": "HS256"}, map[string]any{"exp": now.Unix()}), err: "token expired"},
+		{name: "exp not a number", token: sign(t, "HS256", secret, map[string]any{"alg": "HS256"}, map[string]any{"exp": 1.5}), err: "token expired"},
+		{name: "not valid yet", token: sign(t, "HS256", secret, map[string]any{"alg": "HS256"}, map[string]any{"nbf": now.Unix() + 1}), err: "token not valid yet"},
+		{name: "nbf now", token: sign(t, "HS256", secret, map[string]any{"alg": "HS256"}, map[string]any{"nbf": now.Unix()})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(tt.token, keys, now)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if claims == nil {
+					t.Fatal("expected claims")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyAlg(t *testing.T) {
+	keys, err := ParseJWKS([]byte(fmt.Sprintf(`{"kty": "oct", "alg": "HS512", "k": "%s"}`, b64(secret))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, "HS256", secret, map[string]any{"alg": "HS256"}, map[string]any{})
+	if _, err := Verify(token, keys, now); err == nil {
+		t.Fatal("a key restricted to HS512 must not verify HS256 tokens")
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{name: "not json", doc: "nope", err: "invalid character"},
+		{name: "unsupported key type", doc: `{"kty": "OKP"}`, err: "unsupported key type"},
+		{name: "unsupported curve", doc: `{"kty": "EC", "crv": "P-224"}`, err: "unsupported curve"},
+		{name: "point not on curve", doc: `{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}`, err: "not on the curve"},
+		{name: "only encryption keys", doc: `{"keys": [{"kty": "oct", "use": "enc", "k": "AQ"}]}`, err: "no signing key"},
+		{name: "single jwk", doc: `{"kty": "oct", "k": "AQ"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJWKS([]byte(tt.doc))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	meter           = otel.Meter("github.com/lahabana/api-play/internal/load")
+	loadRequests, _ = meter.Int64Counter("api_play.load.requests", metric.WithDescription("Number of requests sent by the load generator by url and outcome"))
+	loadDuration, _ = meter.Int64Histogram("api_play.load.duration", metric.WithDescription("Duration of the requests sent by the load generator"), metric.WithUnit("ms"))
+)
+
+// MinRps and MaxRps bound a fixed rate so that the interval between requests is a valid duration.
+// Above MaxRps use an rps of 0 to send requests as fast as possible.
+const (
+	MinRps = 0.001
+	MaxRps = 1_000_000
+)
+
+// Conf is what traffic to send.
+type Conf struct {
+	Urls    []string
+	Method  string
+	Headers http.Header
+	Body    string
+	// Rps is the number of requests per second sent in total, when 0 workers send requests as fast as they can.
+	Rps float64
+	// Concurrency is the number of workers sending requests.
+	Concurrency int
+	Duration    time.Duration
+	Timeout     time.Duration
+}
+
+func (c Conf) Validate() error {
+	var errs []error
+	if len(c.Urls) == 0 {
+		errs = append(errs, errors.New("at least one url is required"))
+	}
+	for _, u := range c.Urls {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid url %s: must be an absolute http or https url", u))
+		}
+	}
+	if c.Rps < 0 {
+		errs = append(errs, errors.New("rps must not be negative"))
+	} else if c.Rps > 0 && c.Rps < MinRps {
+		errs = append(errs, fmt.Errorf("rps can't be less than %g", MinRps))
+	}
+	if c.Rps > MaxRps {
+		errs = append(errs, fmt.Errorf("rps can't be greater than %d, use 0 to send requests as fast as possible", MaxRps))
+	}
+	if c.Concurrency < 1 {
+		errs = append(errs, errors.New("concurrency must be greater than 0"))
+	}
+	if c.Duration <= 0 {
+		errs = append(errs, errors.New("duration must be greater than 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// Report is the outcome of a run, overall and for each url.
+type Report struct {
+	Summary
+	DurationMillis int `json:"duration_millis"`
+	// Rps is the number of requests per second actually sent.
+	Rps float64 `json:"rps"`
+	// Missed is the number of requests which weren't sent because all workers were busy, only with a fixed rps.
+	Missed  int       `json:"missed"`
+	Targets []Summary `json:"targets"`
+}
+
+type Summary struct {
+	Url      string `json:"url,omitempty"`
+	Requests int    `json:"requests"`
+	// Statuses are the number of requests by status, requests which didn't get a response are under `network_error`.
+	Statuses  map[string]int   `json:"statuses"`
+	Latency   api.LatencyStats `json:"latency"`
+	durations []time.Duration
+}
+
+func newSummary(url string) *Summary {
+	return &Summary{Url: url, Statuses: map[string]int{}}
+}
+
+func (s *Summary) add(status string, d time.Duration) {
+	s.Requests++
+	s.Statuses[status]++
+	s.durations = append(s.durations, d)
+}
+
+// Run sends traffic until the duration is elapsed or ctx is done.
+func Run(ctx context.Context, conf Conf) Report {
+	ctx, cancel := context.WithTimeout(ctx, conf.Duration)
+	defer cancel()
+	client := &http.Client{Timeout: conf.Timeout}
+	r := &run{conf: conf, client: client, total: newSummary(""), targets: map[string]*Summary{}}
+	for _, u := range conf.Urls {
+		r.targets[u] = newSummary(u)
+	}
+	start := time.Now()
+	var missed int
+	var wg sync.WaitGroup
+	if conf.Rps > 0 {
+		// Workers pick up ticks, a tick is missed when they are all busy so the rate never exceeds rps
+		ticks := make(chan int, conf.Concurrency)
+		for i := 0; i < conf.Concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for n := range ticks {
+					r.send(ctx, n)
+				}
+			}()
+		}
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / conf.Rps))
+		for n := 0; ctx.Err() == nil; n++ {
+			select {
+			case ticks <- n:
+			default:
+				missed++
+			}
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+		ticker.Stop()
+		close(ticks)
+	} else {
+		for i := 0; i < conf.Concurrency; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for n := i; ctx.Err() == nil; n += conf.Concurrency {
+					r.send(ctx, n)
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	report := Report{Summary: *r.total, DurationMillis: int(elapsed.Milliseconds()), Missed: missed, Targets: []Summary{}}
+	report.Latency = api.NewLatencyStats(r.total.durations)
+	report.Rps = float64(report.Requests) / elapsed.Seconds()
+	for _, t := range r.targets {
+		t.Latency = api.NewLatencyStats(t.durations)
+		report.Targets = append(report.Targets, *t)
+	}
+	sort.Slice(report.Targets, func(i, j int) bool {
+		return report.Targets[i].Url < report.Targets[j].Url
+	})
+	return report
+}
+
+type run struct {
+	conf   Conf
+	client *http.Client
+
+	mu      sync.Mutex
+	total   *Summary
+	targets map[string]*Summary
+}
+
+// send sends the nth request, urls are used in turn.
+func (r *run) send(ctx context.Context, n int) {
+	u := r.conf.Urls[n%len(r.conf.Urls)]
+	start := time.Now()
+	status, result := r.do(ctx, u)
+	d := time.Since(start)
+	// Requests interrupted by the end of the run aren't part of the report
+	if ctx.Err() != nil && result == "network_error" {
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("url", u), attribute.String("status", status), attribute.String("result", result))
+	loadRequests.Add(context.Background(), 1, attrs)
+	loadDuration.Record(context.Background(), d.Milliseconds(), attrs)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total.add(status, d)
+	r.targets[u].add(status, d)
+}
+
+// do sends a request, result is `ok`, `http_error` or `network_error` like for the calls of apis.
+func (r *run) do(ctx context.Context, u string) (string, string) {
+	var body io.Reader
+	if r.conf.Body != "" {
+		body = strings.NewReader(r.conf.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.conf.Method, u, body)
+	if err != nil {
+		return "network_error", "network_error"
+	}
+	for k, v := range r.conf.Headers {
+		req.Header[k] = v
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "network_error", "network_error"
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return strconv.Itoa(resp.StatusCode), "http_error"
+	}
+	return strconv.Itoa(resp.StatusCode), "ok"
+}
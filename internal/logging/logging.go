@@ -0,0 +1,200 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	api_errors "github.com/lahabana/api-play/pkg/errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logging creates the loggers of the components of the app, the level of each component can be changed at runtime.
+// A component without its own level uses the default level.
+type Logging struct {
+	handler slog.Handler
+
+	mu           sync.Mutex
+	defaultLevel slog.Level
+	levels       map[string]*slog.LevelVar
+	// explicit are the components with their own level, the others follow the default level.
+	explicit map[string]bool
+	// static are the components whose logger isn't created by Logger, their level can't change at runtime.
+	static map[string]bool
+}
+
+// New creates the loggers of components writing to w in the text or json format.
+// levels are the levels of some of the components, other components are rejected.
+func New(w io.Writer, format string, defaultLevel slog.Level, levels map[string]slog.Level, components []string) (*Logging, error) {
+	opts := &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}
+	var h slog.Handler
+	switch format {
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format: %s valid formats: '%s' and '%s'", format, FormatText, FormatJSON)
+	}
+	l := &Logging{handler: h, defaultLevel: defaultLevel, levels: map[string]*slog.LevelVar{}, explicit: map[string]bool{}, static: map[string]bool{}}
+	for _, component := range components {
+		v := &slog.LevelVar{}
+		v.Set(defaultLevel)
+		l.levels[component] = v
+	}
+	for component, level := range levels {
+		v, ok := l.levels[component]
+		if !ok {
+			return nil, fmt.Errorf("unknown log component: %s valid components: %s", component, strings.Join(components, ", "))
+		}
+		v.Set(level)
+		l.explicit[component] = true
+	}
+	return l, nil
+}
+
+// ParseLevels parses a comma separated list of `component=level`.
+func ParseLevels(s string) (map[string]slog.Level, error) {
+	res := map[string]slog.Level{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		component, level, ok := strings.Cut(entry, "=")
+		if !ok || component == "" {
+			return nil, fmt.Errorf("invalid log level '%s' expected component=level", entry)
+		}
+		l, err := ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		res[component] = l
+	}
+	return res, nil
+}
+
+// ParseLevel parses a level like `debug`, `info`, `warn` or `error`.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return l, fmt.Errorf("invalid log level: %s valid levels: debug, info, warn and error", s)
+	}
+	return l, nil
+}
+
+// Level returns the current level of a component, for loggers which aren't created by Logger.
+// The level of the component can't be changed with SetLevels afterward.
+func (l *Logging) Level(component string) slog.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.static[component] = true
+	return l.levelOf(component).Level()
+}
+
+// Logger returns the logger of a component.
+func (l *Logging) Logger(component string) *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slog.New(&componentHandler{handler: l.handler, level: l.levelOf(component)}).With("name", component)
+}
+
+// levelOf returns the level of a component passed to New, other components are a programming error.
+func (l *Logging) levelOf(component string) *slog.LevelVar {
+	v, ok := l.levels[component]
+	if !ok {
+		panic(fmt.Sprintf("unknown log component: %s", component))
+	}
+	return v
+}
+
+// Levels returns the default level and the level of every component.
+func (l *Logging) Levels() api.LogLevels {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	level := levelName(l.defaultLevel)
+	components := map[string]string{}
+	for k, v := range l.levels {
+		components[k] = levelName(v.Level())
+	}
+	return api.LogLevels{Level: &level, Components: &components}
+}
+
+// SetLevels changes the default level and the levels of the components listed, the others keep their level.
+// It fails without changing anything if a component is unknown or if its level can only be set at startup.
+func (l *Logging) SetLevels(levels api.LogLevels) error {
+	if err := levels.Validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if levels.Components != nil {
+		var components []string
+		for component := range *levels.Components {
+			components = append(components, component)
+		}
+		slices.Sort(components)
+		r := &api_errors.MultiValidationError{}
+		for _, component := range components {
+			if _, ok := l.levels[component]; !ok {
+				r = r.AddRootedAt(fmt.Errorf("unknown component '%s'", component), "components", component)
+			} else if l.static[component] {
+				r = r.AddRootedAt(fmt.Errorf("the level of '%s' can only be set at startup with -log-levels", component), "components", component)
+			}
+		}
+		if err := r.OrNil(); err != nil {
+			return err
+		}
+	}
+	if levels.Level != nil {
+		l.defaultLevel, _ = ParseLevel(*levels.Level)
+		for k, v := range l.levels {
+			// The logger of a static component keeps the level it was created with
+			if !l.explicit[k] && !l.static[k] {
+				v.Set(l.defaultLevel)
+			}
+		}
+	}
+	if levels.Components != nil {
+		for k, level := range *levels.Components {
+			lvl, _ := ParseLevel(level)
+			l.levelOf(k).Set(lvl)
+			l.explicit[k] = true
+		}
+	}
+	return nil
+}
+
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+// componentHandler filters the records of a component by its level.
+type componentHandler struct {
+	handler slog.Handler
+	level   *slog.LevelVar
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &componentHandler{handler: h.handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
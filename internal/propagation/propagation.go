@@ -0,0 +1,46 @@
+package propagation
+
+import (
+	"fmt"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"sort"
+	"strings"
+)
+
+var propagators = map[string]func() propagation.TextMapPropagator{
+	"tracecontext": func() propagation.TextMapPropagator { return propagation.TraceContext{} },
+	"baggage":      func() propagation.TextMapPropagator { return propagation.Baggage{} },
+	"b3":           func() propagation.TextMapPropagator { return b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)) },
+	"b3multi":      func() propagation.TextMapPropagator { return b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)) },
+	"jaeger":       func() propagation.TextMapPropagator { return jaeger.Jaeger{} },
+}
+
+// Names are the propagators that can be used.
+func Names() []string {
+	var res []string
+	for k := range propagators {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Parse builds a propagator from a comma separated list of names (e.g. `tracecontext,baggage`).
+// Context is extracted from any of them and injected with all of them, `none` disables propagation.
+func Parse(names string) (propagation.TextMapPropagator, error) {
+	var res []propagation.TextMapPropagator
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		p, ok := propagators[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid propagator: %s valid propagators: %s and none", name, strings.Join(Names(), ", "))
+		}
+		res = append(res, p())
+	}
+	return propagation.NewCompositeTextMapPropagator(res...), nil
+}
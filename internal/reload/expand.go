@@ -0,0 +1,123 @@
+package reload
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+const (
+	includeTag      = "!include"
+	maxIncludeDepth = 10
+)
+
+var reEnv = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)(:-([^}]*))?}`)
+
+// expandEnv replaces `${VAR}` and `${VAR:-default}` with the value of the environment variable VAR.
+// Like in a shell the default is used when the variable is unset or empty.
+func expandEnv(s string) string {
+	return reEnv.ReplaceAllStringFunc(s, func(m string) string {
+		groups := reEnv.FindStringSubmatch(m)
+		if v := os.Getenv(groups[1]); v != "" {
+			return v
+		}
+		return groups[3]
+	})
+}
+
+// decode expands environment variables and `!include` fragments before decoding the yaml into apis.
+// Variables are expanded in the values of the yaml so their content can't change its structure.
+// Includes are resolved relative to the directory of configFile, an empty configFile means includes are not supported.
+func decode(b []byte, configFile string, apis *api.ParamsAPI) error {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return err
+	}
+	baseDir := ""
+	var includedFrom []string
+	if configFile != "" {
+		absFile, err := filepath.Abs(configFile)
+		if err != nil {
+			return err
+		}
+		baseDir = filepath.Dir(absFile)
+		includedFrom = append(includedFrom, absFile)
+	}
+	if err := expand(doc, baseDir, includedFrom); err != nil {
+		return err
+	}
+	// An empty file has no content, this is the same as an empty config
+	if doc.Kind == 0 {
+		return nil
+	}
+	return doc.Decode(apis)
+}
+
+// expand replaces in place environment variables in scalars and every node tagged with `!include` by the content of the file it references.
+// includedFrom is the chain of files being included and is used to detect cycles.
+func expand(n *yaml.Node, baseDir string, includedFrom []string) error {
+	if n.Kind == yaml.ScalarNode {
+		expandScalar(n)
+	}
+	if n.Tag == includeTag {
+		if n.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: %s must be followed by a file name", n.Line, includeTag)
+		}
+		if baseDir == "" {
+			return fmt.Errorf("line %d: %s is only supported in config files", n.Line, includeTag)
+		}
+		if len(includedFrom) > maxIncludeDepth {
+			return fmt.Errorf("line %d: too many nested includes (max: %d)", n.Line, maxIncludeDepth)
+		}
+		file := n.Value
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(baseDir, file)
+		}
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(includedFrom, file) {
+			return fmt.Errorf("line %d: include cycle on '%s'", n.Line, n.Value)
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		doc := &yaml.Node{}
+		if err := yaml.Unmarshal(b, doc); err != nil {
+			return fmt.Errorf("in '%s': %w", n.Value, err)
+		}
+		if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+			return fmt.Errorf("line %d: included file '%s' is empty", n.Line, n.Value)
+		}
+		if err := expand(doc.Content[0], filepath.Dir(file), append(includedFrom, file)); err != nil {
+			return fmt.Errorf("in '%s': %w", n.Value, err)
+		}
+		*n = *doc.Content[0]
+		return nil
+	}
+	var errs []error
+	for _, c := range n.Content {
+		errs = append(errs, expand(c, baseDir, includedFrom))
+	}
+	return errors.Join(errs...)
+}
+
+// expandScalar expands the environment variables of a scalar.
+// The type of a plain scalar is resolved again from its new value (e.g. `rps: ${RPS:-5}` is a number), quoted ones stay strings.
+func expandScalar(n *yaml.Node) {
+	v := expandEnv(n.Value)
+	if v == n.Value {
+		return
+	}
+	n.Value = v
+	if n.Style == 0 && n.Tag != includeTag {
+		n.Tag = ""
+	}
+}
@@ -2,39 +2,186 @@ package reload
 
 import (
 	"context"
+	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/lahabana/api-play/pkg/api"
-	"gopkg.in/yaml.v3"
+	api_errors "github.com/lahabana/api-play/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
-func reload(ctx context.Context, log *slog.Logger, configFile string, reloader api.Reloader) error {
-	log.InfoContext(ctx, "reloading file", "conf", configFile)
+var reloadCount, _ = otel.Meter("github.com/lahabana/api-play/internal/reload").Int64Counter("api_play.config.reloads",
+	metric.WithDescription("Number of attempts to load the config by source and result"),
+)
+
+var configExtensions = map[string]struct{}{
+	".yaml": {},
+	".yml":  {},
+	".json": {},
+}
+
+// LoadFile reads the apis from a single yaml or json file.
+// Environment variables and `!include` fragments in the file are expanded before decoding it.
+func LoadFile(configFile string) (api.ParamsAPI, error) {
+	apis := api.ParamsAPI{}
 	b, err := os.ReadFile(configFile)
 	if err != nil {
-		return err
+		return apis, err
 	}
-	apis := api.ParamsAPI{}
-	if err := yaml.Unmarshal(b, &apis); err != nil {
-		return err
+	if err := decode(b, configFile, &apis); err != nil {
+		return apis, err
+	}
+	return apis, nil
+}
+
+// LoadDir reads every yaml and json file in configDir and merges them in a single list of apis.
+// Errors are rooted at the name of the file they come from, a path defined in more than one file is an error.
+func LoadDir(configDir string) (api.ParamsAPI, error) {
+	out := api.ParamsAPI{Apis: []api.ConfigureAPIItem{}}
+	files, err := configFiles(configDir)
+	if err != nil {
+		return out, err
 	}
-	if err := reloader.Reload(ctx, apis); err != nil {
+	merr := &api_errors.MultiValidationError{}
+	definedIn := map[string]string{}
+	clientIn := map[string]string{}
+	for _, name := range files {
+		apis, err := LoadFile(filepath.Join(configDir, name))
+		if err != nil {
+			merr = merr.AddRootedAt(err, name)
+			continue
+		}
+		apis.Normalize()
+		// Duplicates are still checked when a file is invalid so that all the errors are reported at once
+		err = apis.Validate()
+		merr = merr.AddRootedAt(err, name)
+		valid := err == nil
+		for i, item := range apis.Apis {
+			key := item.MatchKey()
+			if other, exists := definedIn[key]; exists {
+				merr = merr.AddRootedAt(fmt.Sprintf("duplicate path '%s' already defined in '%s'", item.Path, other), name, "apis", i, "path")
+				continue
+			}
+			definedIn[key] = name
+			if valid {
+				out.Apis = append(out.Apis, item)
+			}
+		}
+		if apis.Clients == nil {
+			continue
+		}
+		for i, client := range *apis.Clients {
+			if other, exists := clientIn[client.Name]; exists {
+				merr = merr.AddRootedAt(fmt.Sprintf("duplicate client '%s' already defined in '%s'", client.Name, other), name, "clients", i, "name")
+				continue
+			}
+			clientIn[client.Name] = name
+			if !valid {
+				continue
+			}
+			if out.Clients == nil {
+				out.Clients = &[]api.ClientDef{}
+			}
+			*out.Clients = append(*out.Clients, client)
+		}
+	}
+	return out, merr.OrNil()
+}
+
+// configFiles lists the names of the config files in a directory in lexical order.
+// Hidden files are ignored which skips the internal files of k8s configMap volumes.
+func configFiles(configDir string) ([]string, error) {
+	entries, err := os.ReadDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, e := range entries {
+		if !isConfigFile(e.Name()) {
+			continue
+		}
+		// Stat follows symlinks, this is how files are exposed in k8s configMap volumes
+		info, err := os.Stat(filepath.Join(configDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() {
+			res = append(res, e.Name())
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func isConfigFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	_, ok := configExtensions[filepath.Ext(name)]
+	return ok
+}
+
+func reload(ctx context.Context, log *slog.Logger, source string, load func() (api.ParamsAPI, error), reloader api.Reloader) error {
+	log.InfoContext(ctx, "reloading config", "source", source)
+	err := doReload(ctx, load, reloader)
+	report(ctx, source, err, reloader)
+	return err
+}
+
+// report counts an attempt to load the config and passes its result to the reloader if it keeps track of them.
+func report(ctx context.Context, source string, err error, reloader api.Reloader) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	reloadCount.Add(ctx, 1, metric.WithAttributes(attribute.String("source", source), attribute.String("result", result)))
+	if reporter, ok := reloader.(api.ReloadReporter); ok {
+		reporter.ReportReload(ctx, source, err)
+	}
+}
+
+func doReload(ctx context.Context, load func() (api.ParamsAPI, error), reloader api.Reloader) error {
+	apis, err := load()
+	if err != nil {
 		return err
 	}
-	return nil
+	return reloader.Reload(ctx, apis)
 }
 
 func BackgroundConfigReload(ctx context.Context, log *slog.Logger, configFile string, reloader api.Reloader) {
-	if err := reload(ctx, log, configFile, reloader); err != nil {
+	load := func() (api.ParamsAPI, error) {
+		return LoadFile(configFile)
+	}
+	// For k8s we react to reload of the config map which is similar to a symlink change
+	watchAndReload(ctx, log, configFile, filepath.Dir(configFile), func(e fsnotify.Event) bool {
+		return filepath.Base(e.Name) == filepath.Base(configFile) || (filepath.Base(e.Name) == "..data" && e.Has(fsnotify.Create))
+	}, load, reloader)
+}
+
+// BackgroundConfigDirReload loads all the config files in configDir and reloads them whenever one of them changes.
+func BackgroundConfigDirReload(ctx context.Context, log *slog.Logger, configDir string, reloader api.Reloader) {
+	load := func() (api.ParamsAPI, error) {
+		return LoadDir(configDir)
+	}
+	watchAndReload(ctx, log, configDir, configDir, func(e fsnotify.Event) bool {
+		return isConfigFile(filepath.Base(e.Name)) || (filepath.Base(e.Name) == "..data" && e.Has(fsnotify.Create))
+	}, load, reloader)
+}
+
+func watchAndReload(ctx context.Context, log *slog.Logger, source string, dirPath string, matches func(fsnotify.Event) bool, load func() (api.ParamsAPI, error), reloader api.Reloader) {
+	if err := reload(ctx, log, source, load, reloader); err != nil {
 		log.ErrorContext(ctx, "config loading failed, server will start with empty config", "error", err)
 	}
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
 		panic(err)
 	}
-	dirPath := filepath.Dir(configFile)
 	if err := w.Add(dirPath); err != nil {
 		panic(err)
 	}
@@ -50,9 +197,8 @@ func BackgroundConfigReload(ctx context.Context, log *slog.Logger, configFile st
 				if !ok {
 					return
 				}
-				// For k8s we react to reload of the config map which is similar to a symlink change
-				if e.Name == filepath.Base(configFile) || (filepath.Base(e.Name) == "..data" && e.Has(fsnotify.Create)) {
-					err := reload(ctx, log, configFile, reloader)
+				if matches(e) {
+					err := reload(ctx, log, source, load, reloader)
 					if err != nil {
 						log.ErrorContext(ctx, "reloading config failed", "error", err)
 					} else {
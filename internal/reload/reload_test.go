@@ -0,0 +1,35 @@
+package reload
+
+import (
+	"github.com/lahabana/api-play/pkg/api"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDirReportsDuplicatesOfInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"1.yaml": "apis:\n  - path: a\n    conf:\n      body: a\n",
+		"2.yaml": "apis:\n  - path: a\n    conf:\n      body: a\n  - path: \"b//\"\n    conf: {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := LoadDir(dir)
+	params := api.InvalidParametersFromError(err)
+	if params == nil {
+		t.Fatalf("expected validation errors, got: %v", err)
+	}
+	fields := map[string]bool{}
+	for _, p := range *params {
+		fields[p.Field] = true
+	}
+	for _, field := range []string{".2.yaml.apis[1].path", ".2.yaml.apis[0].path"} {
+		if !fields[field] {
+			t.Errorf("expected an error on %s, got: %v", field, *params)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+var errNotModified = errors.New("config not modified")
+
+// remoteSource fetches the config from a url, it uses ETags to avoid downloading and reloading a config that didn't change.
+type remoteSource struct {
+	url    string
+	client *http.Client
+	// etag and body are the ones of the last config successfully applied.
+	etag string
+	body []byte
+}
+
+// fetch returns the body of the config or errNotModified if it's the same as the last applied one.
+func (r *remoteSource) fetch(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status fetching config: %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	// Servers without ETag support still shouldn't trigger a reload on every poll
+	if r.body != nil && bytes.Equal(b, r.body) {
+		return nil, "", errNotModified
+	}
+	return b, resp.Header.Get("ETag"), nil
+}
+
+// poll fetches the config and reloads it when it changed.
+func (r *remoteSource) poll(ctx context.Context, log *slog.Logger, reloader api.Reloader) error {
+	b, etag, err := r.fetch(ctx)
+	if err != nil {
+		// An unchanged config isn't an attempt to reload, failing to fetch it is
+		if !errors.Is(err, errNotModified) {
+			report(ctx, r.url, err, reloader)
+		}
+		return err
+	}
+	load := func() (api.ParamsAPI, error) {
+		apis := api.ParamsAPI{}
+		if err := decode(b, "", &apis); err != nil {
+			return apis, err
+		}
+		return apis, nil
+	}
+	if err := reload(ctx, log, r.url, load, reloader); err != nil {
+		return err
+	}
+	// Only remember successful configs so that a broken config keeps being reported until it's fixed
+	r.etag = etag
+	r.body = b
+	return nil
+}
+
+// BackgroundConfigPoll fetches the config from configUrl every interval plus a random jitter and reloads it when it changed.
+func BackgroundConfigPoll(ctx context.Context, log *slog.Logger, configUrl string, interval time.Duration, jitter time.Duration, reloader api.Reloader) {
+	src := &remoteSource{url: configUrl, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := src.poll(ctx, log, reloader); err != nil {
+		log.ErrorContext(ctx, "config loading failed, server will start with empty config", "error", err)
+	}
+	go func() {
+		log.InfoContext(ctx, "polling for config changes", "url", configUrl, "interval", interval, "jitter", jitter)
+		for {
+			wait := interval
+			if jitter > 0 {
+				wait += time.Duration(rand.Int63n(int64(jitter)))
+			}
+			select {
+			case <-time.After(wait):
+				err := src.poll(ctx, log, reloader)
+				switch {
+				case errors.Is(err, errNotModified):
+					log.DebugContext(ctx, "config not modified", "url", configUrl)
+				case err != nil:
+					log.ErrorContext(ctx, "reloading config failed", "error", err)
+				default:
+					log.InfoContext(ctx, "config successfully reloaded")
+				}
+			case <-ctx.Done():
+				log.InfoContext(ctx, "stopping poller")
+				return
+			}
+		}
+	}()
+}
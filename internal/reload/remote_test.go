@@ -0,0 +1,122 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"github.com/lahabana/api-play/pkg/api"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type fakeReloader struct {
+	reloads []api.ParamsAPI
+	reports []error
+}
+
+func (f *fakeReloader) Reload(_ context.Context, apis api.ParamsAPI) error {
+	apis.Normalize()
+	if err := apis.Validate(); err != nil {
+		return err
+	}
+	f.reloads = append(f.reloads, apis)
+	return nil
+}
+
+func (f *fakeReloader) ReportReload(_ context.Context, _ string, err error) {
+	f.reports = append(f.reports, err)
+}
+
+// configServer serves body with status and etag when it's not empty, it answers 304 to a matching If-None-Match.
+type configServer struct {
+	mu     sync.Mutex
+	body   string
+	etag   string
+	status int
+	calls  []string
+}
+
+func (c *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, r.Header.Get("If-None-Match"))
+	if c.etag != "" {
+		if r.Header.Get("If-None-Match") == c.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", c.etag)
+	}
+	w.WriteHeader(c.status)
+	_, _ = io.WriteString(w, c.body)
+}
+
+func (c *configServer) set(body, etag string, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.body, c.etag, c.status = body, etag, status
+}
+
+func (c *configServer) lastIfNoneMatch() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[len(c.calls)-1]
+}
+
+func TestRemoteSourcePoll(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cs := &configServer{}
+	server := httptest.NewServer(cs)
+	defer server.Close()
+	src := &remoteSource{url: server.URL, client: server.Client()}
+	reloader := &fakeReloader{}
+
+	poll := func(t *testing.T, reloads int, reports int, check func(error) bool) {
+		t.Helper()
+		err := src.poll(ctx, log, reloader)
+		if !check(err) {
+			t.Fatalf("unexpected result: %v", err)
+		}
+		if len(reloader.reloads) != reloads {
+			t.Fatalf("expected %d reloads, got %d", reloads, len(reloader.reloads))
+		}
+		if len(reloader.reports) != reports {
+			t.Fatalf("expected %d reports, got %d", reports, len(reloader.reports))
+		}
+	}
+	success := func(err error) bool { return err == nil }
+	notModified := func(err error) bool { return errors.Is(err, errNotModified) }
+	failure := func(err error) bool { return err != nil && !errors.Is(err, errNotModified) }
+
+	// Without an ETag an unchanged body isn't reloaded
+	cs.set("apis:\n  - path: a\n    conf:\n      body: a\n", "", http.StatusOK)
+	poll(t, 1, 1, success)
+	poll(t, 1, 1, notModified)
+
+	// 200 with an ETag is reloaded and its ETag is sent on the next poll which gets a 304
+	cs.set("apis:\n  - path: b\n    conf:\n      body: b\n", `"v1"`, http.StatusOK)
+	poll(t, 2, 2, success)
+	if path := reloader.reloads[1].Apis[0].Path; path != "b" {
+		t.Fatalf("expected the new config, got %s", path)
+	}
+	poll(t, 2, 2, notModified)
+	if last := cs.lastIfNoneMatch(); last != `"v1"` {
+		t.Fatalf("expected If-None-Match with the last etag, got %q", last)
+	}
+
+	// A broken config is reported on every poll until it's fixed, its ETag isn't remembered
+	cs.set("apis:\n  - path: \"\"\n", `"broken"`, http.StatusOK)
+	poll(t, 2, 3, failure)
+	poll(t, 2, 4, failure)
+	if last := cs.lastIfNoneMatch(); last != `"v1"` {
+		t.Fatalf("expected If-None-Match with the last applied etag, got %q", last)
+	}
+
+	// Failing to fetch is reported
+	cs.set("", "", http.StatusInternalServerError)
+	poll(t, 2, 5, failure)
+}
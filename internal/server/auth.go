@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/lahabana/api-play/internal/jwt"
+	"github.com/lahabana/api-play/pkg/api"
+	api_errors "github.com/lahabana/api-play/pkg/errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultApiKeyHeader = "X-API-Key"
+
+// authenticator checks the credentials of the requests to an api.
+type authenticator struct {
+	conf api.AuthDef
+	keys []jwt.Key
+}
+
+func newAuthenticator(conf *api.AuthDef) (*authenticator, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	a := &authenticator{conf: *conf}
+	if conf.Jwt != nil {
+		keys, err := loadKeys(*conf.Jwt)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+	return a, nil
+}
+
+// loadKeys reads the keys to verify tokens with, from the inline JWKS or the JWKS file.
+// Errors are validation errors rooted at the field of the keys.
+func loadKeys(conf api.JWTAuthDef) ([]jwt.Key, error) {
+	field, b := "jwks", []byte(valueOr(conf.Jwks, ""))
+	if conf.JwksFile != nil {
+		field = "jwks_file"
+		var err error
+		if b, err = os.ReadFile(*conf.JwksFile); err != nil {
+			return nil, (&api_errors.MultiValidationError{}).AddRootedAt(fmt.Sprintf("invalid keys: %s", err.Error()), "auth", "jwt", field).OrNil()
+		}
+	}
+	keys, err := jwt.ParseJWKS(b)
+	if err != nil {
+		return nil, (&api_errors.MultiValidationError{}).AddRootedAt(fmt.Sprintf("invalid keys: %s", err.Error()), "auth", "jwt", field).OrNil()
+	}
+	return keys, nil
+}
+
+// ValidateKeys loads the JWKS of the apis and their variants, it reports the same errors as the server when loading apis.
+func ValidateKeys(apis api.ParamsAPI) error {
+	merr := &api_errors.MultiValidationError{}
+	for i, item := range apis.Apis {
+		merr = merr.AddRootedAt(validateKeys(item.Conf), "apis", i, "conf")
+	}
+	return merr.OrNil()
+}
+
+func validateKeys(conf api.ConfigureAPI) error {
+	merr := &api_errors.MultiValidationError{}
+	if conf.Auth != nil && conf.Auth.Jwt != nil {
+		_, err := loadKeys(*conf.Auth.Jwt)
+		merr = merr.AddRootedAt(err)
+	}
+	if conf.Variants != nil {
+		for i, v := range *conf.Variants {
+			merr = merr.AddRootedAt(validateKeys(v.Conf), "variants", i, "conf")
+		}
+	}
+	return merr.OrNil()
+}
+
+// check returns 0 if the request is allowed or the status to respond with otherwise.
+// When the request is allowed with a JWT its claims are returned.
+func (a *authenticator) check(req *http.Request, now time.Time) (int, map[string]any) {
+	if a == nil {
+		return 0, nil
+	}
+	if a.conf.Key != nil {
+		header := defaultApiKeyHeader
+		if a.conf.Key.Header != nil {
+			header = *a.conf.Key.Header
+		}
+		key := req.Header.Get(header)
+		if key == "" && a.conf.Key.Query != nil {
+			key = req.URL.Query().Get(*a.conf.Key.Query)
+		}
+		if key != "" && slices.ContainsFunc(a.conf.Key.Keys, func(k string) bool { return equal(k, key) }) {
+			return 0, nil
+		}
+	}
+	if a.conf.Basic != nil {
+		if user, password, ok := req.BasicAuth(); ok {
+			if expected, exists := a.conf.Basic.Users[user]; exists && equal(password, expected) {
+				return 0, nil
+			}
+		}
+	}
+	if a.conf.Jwt != nil {
+		if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			claims, err := jwt.Verify(token, a.keys, now)
+			if err == nil && a.validClaims(claims) {
+				if !a.expectedClaims(claims) {
+					return http.StatusForbidden, nil
+				}
+				return 0, claims
+			}
+		}
+	}
+	return http.StatusUnauthorized, nil
+}
+
+// validClaims checks the issuer and the audience of a token, a token failing them is invalid for this api.
+func (a *authenticator) validClaims(claims map[string]any) bool {
+	conf := a.conf.Jwt
+	if conf.Issuer != nil && claims["iss"] != *conf.Issuer {
+		return false
+	}
+	if conf.Audiences != nil {
+		var aud []string
+		switch v := claims["aud"].(type) {
+		case string:
+			aud = []string{v}
+		case []any:
+			for _, e := range v {
+				if s, ok := e.(string); ok {
+					aud = append(aud, s)
+				}
+			}
+		}
+		if !slices.ContainsFunc(*conf.Audiences, func(expected string) bool { return slices.Contains(aud, expected) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// expectedClaims checks the claims of a valid token, a token failing them is not allowed to use this api.
+func (a *authenticator) expectedClaims(claims map[string]any) bool {
+	if a.conf.Jwt.Claims == nil {
+		return true
+	}
+	for k, v := range *a.conf.Jwt.Claims {
+		field, ok := lookupField(claims, k)
+		if !ok || field != v {
+			return false
+		}
+	}
+	return true
+}
+
+// challenge is the WWW-Authenticate header to return with a 401.
+func (a *authenticator) challenge() string {
+	var res []string
+	if a.conf.Jwt != nil {
+		res = append(res, `Bearer realm="api-play"`)
+	}
+	if a.conf.Basic != nil {
+		res = append(res, `Basic realm="api-play"`)
+	}
+	return strings.Join(res, ", ")
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"github.com/lahabana/api-play/pkg/api"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// maxClientInFlight is the number of ticks of a client which can be in flight, the next ones are skipped.
+const maxClientInFlight = 100
+
+// clients runs the clients of the config in the background.
+type clients struct {
+	l   *slog.Logger
+	srv *srv
+
+	mu      sync.Mutex
+	running map[string]*client
+	// confs are the clients in the order of the config.
+	confs []api.ClientDef
+}
+
+type client struct {
+	conf   api.ClientDef
+	cancel context.CancelFunc
+}
+
+// update starts the new and changed clients and stops the ones which changed or were removed.
+// Clients which didn't change keep running.
+func (c *clients) update(ctx context.Context, confs []api.ClientDef) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := map[string]*client{}
+	for _, conf := range confs {
+		if cl, ok := c.running[conf.Name]; ok && reflect.DeepEqual(cl.conf, conf) {
+			next[conf.Name] = cl
+			continue
+		}
+		clientCtx, cancel := context.WithCancel(context.Background())
+		next[conf.Name] = &client{conf: conf, cancel: cancel}
+		go c.run(clientCtx, conf)
+	}
+	for name, cl := range c.running {
+		if next[name] != cl {
+			cl.cancel()
+			c.l.InfoContext(ctx, "stopped client", "client", name)
+		}
+	}
+	for name, cl := range next {
+		if c.running[name] != cl {
+			c.l.InfoContext(ctx, "started client", "client", name, "rps", cl.conf.Rps, "calls", len(cl.conf.Call))
+		}
+	}
+	c.running = next
+	c.confs = confs
+}
+
+func (c *clients) list() []api.ClientDef {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.confs
+}
+
+// run makes the calls of a client at its rate until ctx is done.
+func (c *clients) run(ctx context.Context, conf api.ClientDef) {
+	m := clientRecorder{ctx: context.Background(), name: conf.Name}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / float64(conf.Rps)))
+	defer ticker.Stop()
+	inFlight := make(chan struct{}, maxClientInFlight)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case inFlight <- struct{}{}:
+			go func() {
+				defer func() { <-inFlight }()
+				for _, call := range conf.Call {
+					c.srv.call(ctx, call, m.call)
+				}
+			}()
+		default:
+			m.skipped()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"github.com/lahabana/api-play/pkg/api"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// limiter bounds the number of requests an api handles at the same time.
+type limiter struct {
+	slots        chan struct{}
+	queued       atomic.Int64
+	queueSize    int64
+	queueTimeout time.Duration
+	status       int
+}
+
+func newLimiter(conf *api.ConcurrencyDef) *limiter {
+	if conf == nil {
+		return nil
+	}
+	l := &limiter{slots: make(chan struct{}, conf.MaxInFlight), status: http.StatusServiceUnavailable}
+	if conf.QueueSize != nil {
+		l.queueSize = int64(*conf.QueueSize)
+	}
+	if conf.QueueTimeoutMillis != nil {
+		l.queueTimeout = time.Duration(*conf.QueueTimeoutMillis) * time.Millisecond
+	}
+	if conf.OverloadStatus != nil {
+		l.status = *conf.OverloadStatus
+	}
+	return l
+}
+
+// acquire takes a slot, waiting in the queue if the limit is reached.
+// It returns false if the request is rejected, otherwise release must be called once the request is handled.
+func (l *limiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queued.Add(1) > l.queueSize {
+		l.queued.Add(-1)
+		return false
+	}
+	defer l.queued.Add(-1)
+	if l.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.queueTimeout)
+		defer cancel()
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *limiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxMatchBodySize is the biggest body that is read to match variants on json fields.
+const maxMatchBodySize = 1 << 20
+
+// requestMatcher checks the conditions of variants against a request, the body is only read if a condition needs it.
+type requestMatcher struct {
+	req      *http.Request
+	bodyRead bool
+	body     any
+}
+
+func (r *requestMatcher) matches(m api.MatchDef) bool {
+	if m.Method != nil && *m.Method != r.req.Method {
+		return false
+	}
+	if m.Headers != nil {
+		for k, v := range *m.Headers {
+			if r.req.Header.Get(k) != v {
+				return false
+			}
+		}
+	}
+	if m.Query != nil {
+		query := r.req.URL.Query()
+		for k, v := range *m.Query {
+			if !query.Has(k) || query.Get(k) != v {
+				return false
+			}
+		}
+	}
+	if m.Body != nil {
+		body := r.jsonBody()
+		for k, v := range *m.Body {
+			field, ok := lookupField(body, k)
+			if !ok || field != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// jsonBody decodes the body of the request as json and puts it back so it can still be read.
+func (r *requestMatcher) jsonBody() any {
+	if r.bodyRead {
+		return r.body
+	}
+	r.bodyRead = true
+	if r.req.Body == nil {
+		return nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.req.Body, maxMatchBodySize))
+	_ = r.req.Body.Close()
+	r.req.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&r.body); err != nil {
+		r.body = nil
+	}
+	return r.body
+}
+
+// lookupField returns the value at a `.` separated path in a decoded json document as a string.
+// Segments of the path are keys of objects or indexes of arrays.
+func lookupField(doc any, path string) (string, bool) {
+	cur := doc
+	for _, k := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[k]
+			if !ok {
+				return "", false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			cur = v[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := cur.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "null", true
+	case json.Number, bool:
+		return fmt.Sprint(v), true
+	default:
+		b, _ := json.Marshal(v)
+		return string(b), true
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/lahabana/api-play/internal/accesslog"
+	"github.com/lahabana/api-play/pkg/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"slices"
+	"time"
+)
+
+const (
+	faultStatus      = "status"
+	faultLatency     = "latency"
+	faultAuth        = "auth"
+	faultRateLimit   = "rate_limit"
+	faultConcurrency = "concurrency"
+)
+
+var (
+	meter           = otel.Meter("github.com/lahabana/api-play/internal/server")
+	apiRequests, _  = meter.Int64Counter("api_play.api.requests", metric.WithDescription("Number of requests to dynamic apis by chosen status"))
+	apiLatency, _   = meter.Int64Histogram("api_play.api.injected_latency", metric.WithDescription("Latency injected in requests to dynamic apis"), metric.WithUnit("ms"))
+	apiFaults, _    = meter.Int64Counter("api_play.api.faults", metric.WithDescription("Number of faults injected in dynamic apis by type"))
+	apiCalls, _     = meter.Int64Counter("api_play.api.calls", metric.WithDescription("Number of calls made by dynamic apis by url and outcome"))
+	apiCallsTime, _ = meter.Int64Histogram("api_play.api.call_duration", metric.WithDescription("Duration of the calls made by dynamic apis"), metric.WithUnit("ms"))
+
+	clientCalls, _     = meter.Int64Counter("api_play.client.calls", metric.WithDescription("Number of calls made by clients by url and outcome"))
+	clientCallsTime, _ = meter.Int64Histogram("api_play.client.call_duration", metric.WithDescription("Duration of the calls made by clients"), metric.WithUnit("ms"))
+	clientSkipped, _   = meter.Int64Counter("api_play.client.skipped", metric.WithDescription("Number of ticks of clients skipped because too many calls were in flight"))
+)
+
+// recorder records what was injected in a request to a dynamic api in metrics, in the access log and in the stats of the api.
+type recorder struct {
+	ctx     context.Context
+	attrs   []attribute.KeyValue
+	ann     *accesslog.Annotation
+	stats   *stats
+	summary *api.RequestSummary
+}
+
+// newRecorder starts recording a request to b, done must be called once the request is handled.
+func newRecorder(c *gin.Context, rt *route, b *behaviour, variant string) recorder {
+	ann := accesslog.Annotate(c)
+	ann.Api = rt.path
+	ann.Method = rt.method
+	ann.Variant = variant
+	b.stats.inFlight.Add(1)
+	return recorder{ctx: c.Request.Context(), ann: ann, stats: b.stats, summary: &api.RequestSummary{Time: time.Now()}, attrs: []attribute.KeyValue{
+		attribute.String("api", rt.path),
+		attribute.String("method", rt.method),
+		attribute.String("variant", variant),
+	}}
+}
+
+// request records the status of the request, chosen is the entry of the config which gave this status if any.
+func (m recorder) request(status int, chosen string) {
+	m.ann.Chosen = chosen
+	m.summary.Status = status
+	if chosen != "" {
+		m.summary.Chosen = &chosen
+	}
+	apiRequests.Add(m.ctx, 1, metric.WithAttributes(append(m.attrs, attribute.Int("status", status))...))
+}
+
+// done adds the request to the stats of the api.
+func (m recorder) done() {
+	m.stats.inFlight.Add(-1)
+	if m.summary.Status == 0 {
+		return
+	}
+	d := time.Since(m.summary.Time)
+	m.summary.DurationMillis = int(d.Milliseconds())
+	if len(m.ann.Faults) > 0 {
+		faults := slices.Clone(m.ann.Faults)
+		m.summary.Faults = &faults
+	}
+	m.stats.record(*m.summary, d)
+}
+
+func (m recorder) fault(kind string) {
+	m.ann.Faults = append(m.ann.Faults, kind)
+	apiFaults.Add(m.ctx, 1, metric.WithAttributes(append(m.attrs, attribute.String("type", kind))...))
+}
+
+func (m recorder) latency(d time.Duration) {
+	m.ann.InjectedLatency = d.Milliseconds()
+	apiLatency.Record(m.ctx, d.Milliseconds(), metric.WithAttributes(m.attrs...))
+}
+
+// call records the outcome of a call, result is `ok`, `http_error` or `network_error`.
+func (m recorder) call(url string, status int, result string, d time.Duration) {
+	m.ann.Calls = append(m.ann.Calls, accesslog.Call{Url: url, Status: status, Result: result})
+	attrs := metric.WithAttributes(append(m.attrs, attribute.String("url", url), attribute.Int("status", status), attribute.String("result", result))...)
+	apiCalls.Add(m.ctx, 1, attrs)
+	apiCallsTime.Record(m.ctx, d.Milliseconds(), attrs)
+}
+
+// clientRecorder records the calls made by a client of the config.
+type clientRecorder struct {
+	ctx  context.Context
+	name string
+}
+
+func (m clientRecorder) call(url string, status int, result string, d time.Duration) {
+	attrs := metric.WithAttributes(attribute.String("client", m.name), attribute.String("url", url), attribute.Int("status", status), attribute.String("result", result))
+	clientCalls.Add(m.ctx, 1, attrs)
+	clientCallsTime.Record(m.ctx, d.Milliseconds(), attrs)
+}
+
+func (m clientRecorder) skipped() {
+	clientSkipped.Add(m.ctx, 1, metric.WithAttributes(attribute.String("client", m.name)))
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"sync"
+)
+
+// lockedRand is a random source safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newApiRand derives the random source of an api from the seed of the server and the identity of the api.
+// With the same seed each api draws the same numbers regardless of the other apis and of the order they're loaded in.
+func newApiRand(seed int64, parts ...string) *lockedRand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(seed, 10)))
+	for _, p := range parts {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(p))
+	}
+	return &lockedRand{r: rand.New(rand.NewSource(int64(h.Sum64())))}
+}
+
+// Intn returns a number in [0, n), it returns 0 when n <= 0.
+func (l *lockedRand) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
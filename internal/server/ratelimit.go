@@ -0,0 +1,110 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lahabana/api-play/pkg/api"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitBuckets is the number of keys above which idle buckets are dropped.
+const maxRateLimitBuckets = 10_000
+
+// rateLimiter is a token bucket per key of the requests.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	key    string
+	header string
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(conf *api.RateLimitDef) *rateLimiter {
+	if conf == nil {
+		return nil
+	}
+	r := &rateLimiter{
+		rate:    float64(conf.Rate),
+		burst:   math.Ceil(float64(conf.Rate)),
+		key:     api.RateLimitKeyGlobal,
+		buckets: map[string]*bucket{},
+	}
+	if conf.Burst != nil {
+		r.burst = float64(*conf.Burst)
+	}
+	if conf.Key != nil {
+		r.key = *conf.Key
+	}
+	if conf.Header != nil {
+		r.header = *conf.Header
+	}
+	return r
+}
+
+func (r *rateLimiter) keyOf(c *gin.Context) string {
+	switch r.key {
+	case api.RateLimitKeyClientIP:
+		return c.ClientIP()
+	case api.RateLimitKeyHeader:
+		return c.GetHeader(r.header)
+	default:
+		return ""
+	}
+}
+
+// allow takes a token from the bucket of the request and sets the rate limit headers of the response.
+// It returns false when the bucket is empty.
+func (r *rateLimiter) allow(c *gin.Context, now time.Time) bool {
+	if r == nil {
+		return true
+	}
+	key := r.keyOf(c)
+	r.mu.Lock()
+	b, ok := r.buckets[key]
+	if !ok {
+		if len(r.buckets) >= maxRateLimitBuckets {
+			r.dropFullBuckets(now)
+		}
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	b.refill(now, r.rate, r.burst)
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	tokens := b.tokens
+	r.mu.Unlock()
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(int(r.burst)))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(int(tokens)))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((r.burst-tokens)/r.rate))))
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil((1-tokens)/r.rate))))
+	}
+	return allowed
+}
+
+// dropFullBuckets removes the buckets which have been idle long enough to be full, they're the same as new buckets.
+func (r *rateLimiter) dropFullBuckets(now time.Time) {
+	for k, b := range r.buckets {
+		b.refill(now, r.rate, r.burst)
+		if b.tokens >= r.burst {
+			delete(r.buckets, k)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time, rate float64, burst float64) {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
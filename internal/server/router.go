@@ -0,0 +1,320 @@
+package server
+
+import (
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// apiKey identifies an api, apis with the same path can have a different configuration for each method.
+type apiKey struct {
+	method string
+	path   string
+}
+
+func keyOf(item api.ConfigureAPIItem) apiKey {
+	return apiKey{method: *api.NormalizeMethod(item.Method), path: item.Path}
+}
+
+// matchKey is the same for apis which match the same requests, like `users/{id}` and `users/{uid}`.
+func (k apiKey) matchKey() string {
+	return k.method + " " + api.CanonicalPath(k.path)
+}
+
+// conflict returns an api of apis other than key which matches the same requests.
+func conflict(apis map[apiKey]api.ConfigureAPI, key apiKey) (apiKey, bool) {
+	for k := range apis {
+		if k != key && k.matchKey() == key.matchKey() {
+			return k, true
+		}
+	}
+	return apiKey{}, false
+}
+
+// merge adds overrides to config, an override replaces the apis of config which match the same requests.
+// It returns the apis of config which were replaced this way.
+func merge(config map[apiKey]api.ConfigureAPI, overrides map[apiKey]api.ConfigureAPI) (map[apiKey]api.ConfigureAPI, []apiKey) {
+	res := maps.Clone(config)
+	var shadowed []apiKey
+	for k, conf := range overrides {
+		if other, ok := conflict(res, k); ok {
+			delete(res, other)
+			shadowed = append(shadowed, other)
+		}
+		res[k] = conf
+	}
+	return res, shadowed
+}
+
+// items lists apis sorted by path and method.
+func items(apis map[apiKey]api.ConfigureAPI) []api.ConfigureAPIItem {
+	var keys []apiKey
+	for k := range apis {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].method < keys[j].method
+	})
+	out := []api.ConfigureAPIItem{}
+	for _, k := range keys {
+		method := k.method
+		out = append(out, api.ConfigureAPIItem{Conf: apis[k], Path: k.path, Method: &method})
+	}
+	return out
+}
+
+// behaviour is how an api or one of its variants responds to requests.
+type behaviour struct {
+	conf api.ConfigureAPI
+	body *template.Template
+	auth *authenticator
+	// limit, rateLimit and requests are shared by all the requests to this behaviour until its config changes
+	limit     *limiter
+	rateLimit *rateLimiter
+	// requests counts the requests which got a status, it's used by sequences and patterns
+	requests *atomic.Int64
+	// rand picks the latency and the status of requests
+	rand  *lockedRand
+	stats *stats
+}
+
+func newBehaviour(conf api.ConfigureAPI, rand *lockedRand) (behaviour, error) {
+	// The body was validated before being added, so this can't fail
+	body, _ := api.ParseBodyTemplate(conf.Body)
+	// The JWKS of auth is loaded here, validation only checks the config
+	auth, err := newAuthenticator(conf.Auth)
+	if err != nil {
+		return behaviour{}, err
+	}
+	return behaviour{
+		conf:      conf,
+		body:      body,
+		auth:      auth,
+		limit:     newLimiter(conf.Concurrency),
+		rateLimit: newRateLimiter(conf.RateLimit),
+		requests:  &atomic.Int64{},
+		rand:      rand,
+		stats:     newStats(time.Now()),
+	}, nil
+}
+
+// inherit takes the state of prev if it has the same config, so that it isn't reset when other apis change.
+// The body and the keys of auth are still rebuilt to pick up changes of the files they depend on.
+func (b *behaviour) inherit(prev *behaviour) {
+	if !sameBehaviour(prev.conf, b.conf) {
+		return
+	}
+	b.limit = prev.limit
+	b.rateLimit = prev.rateLimit
+	b.requests = prev.requests
+	b.rand = prev.rand
+	b.stats = prev.stats
+}
+
+// share uses the auth and limits of the api for the ones a variant doesn't override.
+// Limits are shared with the api so matching a variant can't be used to skip them.
+func (b *behaviour) share(parent *behaviour) {
+	if b.conf.Auth == nil {
+		b.auth = parent.auth
+	}
+	if b.conf.RateLimit == nil {
+		b.rateLimit = parent.rateLimit
+	}
+	if b.conf.Concurrency == nil {
+		b.limit = parent.limit
+	}
+}
+
+// sameBehaviour compares configs without their variants which have their own behaviour.
+func sameBehaviour(a, b api.ConfigureAPI) bool {
+	a.Variants, b.Variants = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+type variant struct {
+	behaviour
+	name  string
+	match api.MatchDef
+}
+
+// route is an api ready to serve requests.
+type route struct {
+	behaviour
+	method   string
+	path     string
+	segments []api.PathSegment
+	variants []*variant
+}
+
+// variant returns the variant with this name if there's one.
+func (rt *route) variant(name string) *variant {
+	for _, v := range rt.variants {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// behaviourFor returns the first variant matching the request or the default behaviour of the route.
+// The name of the variant is empty for the default behaviour.
+func (rt *route) behaviourFor(req *http.Request) (*behaviour, string) {
+	if len(rt.variants) == 0 {
+		return &rt.behaviour, ""
+	}
+	m := &requestMatcher{req: req}
+	for _, v := range rt.variants {
+		if m.matches(v.match) {
+			return &v.behaviour, v.name
+		}
+	}
+	return &rt.behaviour, ""
+}
+
+// router finds the api matching a request.
+type router struct {
+	apis map[apiKey]api.ConfigureAPI
+	// routes are sorted by precedence
+	routes []*route
+}
+
+// newRouter builds the routes of apis, the random source of each api and variant is derived from seed.
+// The apis and variants whose config is the same as in prev keep their state (limits, counters, random sources and stats).
+func newRouter(apis map[apiKey]api.ConfigureAPI, seed int64, prev *router) (*router, error) {
+	previous := map[apiKey]*route{}
+	if prev != nil {
+		for _, rt := range prev.routes {
+			previous[apiKey{method: rt.method, path: rt.path}] = rt
+		}
+	}
+	r := &router{apis: apis}
+	for k, conf := range apis {
+		b, err := newBehaviour(conf, newApiRand(seed, k.method, k.path))
+		if err != nil {
+			return nil, fmt.Errorf("api %s %s: %w", k.method, k.path, err)
+		}
+		old := previous[k]
+		if old != nil {
+			b.inherit(&old.behaviour)
+		}
+		rt := &route{behaviour: b, method: k.method, path: k.path, segments: api.ParsePath(k.path)}
+		if conf.Variants != nil {
+			for i, v := range *conf.Variants {
+				name := fmt.Sprintf("variants[%d]", i)
+				if v.Name != nil {
+					name = *v.Name
+				}
+				b, err := newBehaviour(v.Conf, newApiRand(seed, k.method, k.path, name))
+				if err != nil {
+					return nil, fmt.Errorf("api %s %s %s: %w", k.method, k.path, name, err)
+				}
+				if old != nil {
+					if oldVariant := old.variant(name); oldVariant != nil {
+						b.inherit(&oldVariant.behaviour)
+					}
+				}
+				b.share(&rt.behaviour)
+				rt.variants = append(rt.variants, &variant{behaviour: b, name: name, match: v.Match})
+			}
+		}
+		r.routes = append(r.routes, rt)
+	}
+	sort.Slice(r.routes, func(i, j int) bool {
+		return morePrecise(r.routes[i], r.routes[j])
+	})
+	return r, nil
+}
+
+// stats returns the statistics of every route and variant sorted by path and method.
+func (r *router) stats() api.Stats {
+	routes := slices.Clone(r.routes)
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].path != routes[j].path {
+			return routes[i].path < routes[j].path
+		}
+		return routes[i].method < routes[j].method
+	})
+	out := api.Stats{Apis: []api.ApiStats{}}
+	for _, rt := range routes {
+		st := rt.stats.snapshot(rt.conf)
+		st.Path, st.Method = rt.path, rt.method
+		out.Apis = append(out.Apis, st)
+		for _, v := range rt.variants {
+			name := v.name
+			st := v.stats.snapshot(v.conf)
+			st.Path, st.Method, st.Variant = rt.path, rt.method, &name
+			out.Apis = append(out.Apis, st)
+		}
+	}
+	return out
+}
+
+// morePrecise orders routes so that the first one matching a path is the one with the highest precedence.
+// Segments are compared from left to right: a literal wins over a `{param}` which wins over `*` which wins over `**`.
+func morePrecise(a, b *route) bool {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if a.segments[i].Kind != b.segments[i].Kind {
+			return a.segments[i].Kind < b.segments[i].Kind
+		}
+	}
+	if len(a.segments) != len(b.segments) {
+		return len(a.segments) > len(b.segments)
+	}
+	if a.path != b.path {
+		return a.path < b.path
+	}
+	return a.method < b.method
+}
+
+// match returns the route with the highest precedence for a request and the value of its parameters.
+// When no route exists for the method, it returns the methods of the routes which match the path.
+func (r *router) match(method string, path string) (*route, map[string]string, []string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var allowed []string
+	for _, rt := range r.routes {
+		params, ok := rt.match(parts)
+		if !ok {
+			continue
+		}
+		if rt.method == method {
+			return rt, params, nil
+		}
+		if !slices.Contains(allowed, rt.method) {
+			allowed = append(allowed, rt.method)
+		}
+	}
+	sort.Strings(allowed)
+	return nil, nil, allowed
+}
+
+func (rt *route) match(parts []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, s := range rt.segments {
+		if s.Kind == api.SegmentCatchAll {
+			return params, true
+		}
+		if i >= len(parts) || parts[i] == "" {
+			return nil, false
+		}
+		switch s.Kind {
+		case api.SegmentLiteral:
+			if s.Value != parts[i] {
+				return nil, false
+			}
+		case api.SegmentParam:
+			params[s.Value] = parts[i]
+		}
+	}
+	return params, len(parts) == len(rt.segments)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/lahabana/api-play/pkg/api"
+	"time"
+)
+
+// scheduleFactor returns how much of a scheduled entry applies after the server ran for elapsed.
+// It's 0 when the entry is inactive, 1 when it's fully active and in between during a ramp.
+func scheduleFactor(sched *api.ScheduleDef, elapsed time.Duration) float64 {
+	if sched == nil {
+		return 1
+	}
+	t := elapsed.Seconds()
+	start := float64(valueOr(sched.StartAfterSeconds, 0))
+	if t < start {
+		return 0
+	}
+	if sched.StopAfterSeconds != nil && t >= float64(*sched.StopAfterSeconds) {
+		return 0
+	}
+	// inWindow is the time since the start of the current active window
+	inWindow := t - start
+	if sched.EverySeconds != nil {
+		every := float64(*sched.EverySeconds)
+		inWindow -= every * float64(int64(inWindow/every))
+		if inWindow >= float64(valueOr(sched.DurationSeconds, *sched.EverySeconds)) {
+			return 0
+		}
+	}
+	if sched.RampSeconds != nil && inWindow < float64(*sched.RampSeconds) {
+		return inWindow / float64(*sched.RampSeconds)
+	}
+	return 1
+}
+
+func valueOr[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
@@ -4,32 +4,104 @@ import (
 	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/lahabana/api-play/internal/state"
 	"github.com/lahabana/api-play/internal/version"
 	"github.com/lahabana/api-play/pkg/api"
+	api_errors "github.com/lahabana/api-play/pkg/errors"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"log/slog"
 	"maps"
-	"math/rand"
 	"net/http"
 	"net/http/httptrace"
 	"os"
+	"reflect"
 	"runtime"
-	"sort"
-	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
+const (
+	// dynamicPrefix is the root of the dynamic apis in the openapi spec
+	dynamicPrefix = "/api/dynamic/"
+	// adminApisPrefix is the root of the configuration of the dynamic apis in the openapi spec
+	adminApisPrefix = "/admin/apis/"
+)
+
 type srv struct {
 	healthStatus atomic.Int32
 	readyStatus  atomic.Int32
-	apis         atomic.Pointer[map[string]api.ConfigureAPI]
-	rand         *rand.Rand
-	l            *slog.Logger
+	apis         atomic.Pointer[router]
+	// seed is what the random sources of the apis are derived from, the same seed and requests give the same responses.
+	seed int64
+	l    *slog.Logger
+
+	// mu serializes updates of the apis, reads only use the atomic pointer.
+	mu sync.Mutex
+	// config is the last set of apis loaded with Reload.
+	config map[apiKey]api.ConfigureAPI
+	// overrides are the apis configured at runtime, they are only tracked when a state store is set.
+	overrides map[apiKey]api.ConfigureAPI
+	state     *state.Store
+	// apiPrefix is where dynamic apis are served with any method, it always ends with a `/`.
+	apiPrefix string
+	// controlMiddlewares run before control endpoints which are not routed by the openapi spec.
+	controlMiddlewares []api.MiddlewareFunc
+
+	reloadStatus atomic.Pointer[api.ReloadStatus]
+	configSchema map[string]any
+	logLevels    LogLevels
+	// clients are the background calls of the config.
+	clients *clients
+	// start is the time schedules of statuses and latencies are relative to.
+	start time.Time
+}
+
+type Option func(s *srv)
+
+// WithStateStore persists the apis configured at runtime in the store.
+// These apis take precedence over the ones with the same path in the config on every reload and restart.
+func WithStateStore(store *state.Store) Option {
+	return func(s *srv) {
+		s.state = store
+	}
+}
+
+// WithApiPrefix serves the dynamic apis under prefix with the method they are configured with.
+// With `/` dynamic apis look like the routes of a real service.
+func WithApiPrefix(prefix string) Option {
+	return func(s *srv) {
+		s.apiPrefix = strings.TrimSuffix(prefix, "/") + "/"
+	}
+}
+
+// WithControlMiddlewares runs middlewares before the control endpoints served in NoRoute.
+// These should be the same as the ones in api.GinServerOptions.
+func WithControlMiddlewares(middlewares ...api.MiddlewareFunc) Option {
+	return func(s *srv) {
+		s.controlMiddlewares = middlewares
+	}
+}
+
+// LogLevels gets and changes the levels of the loggers at runtime.
+type LogLevels interface {
+	Levels() api.LogLevels
+	SetLevels(levels api.LogLevels) error
+}
+
+// WithLogLevels serves the levels of the loggers on `/admin/log-levels`.
+func WithLogLevels(levels LogLevels) Option {
+	return func(s *srv) {
+		s.logLevels = levels
+	}
 }
 
 func (s *srv) Reload(ctx context.Context, apis api.ParamsAPI) error {
@@ -37,15 +109,132 @@ func (s *srv) Reload(ctx context.Context, apis api.ParamsAPI) error {
 	if err := apis.Validate(); err != nil {
 		return err
 	}
-	newApis := map[string]api.ConfigureAPI{}
+	newConfig := map[apiKey]api.ConfigureAPI{}
 	for _, item := range apis.Apis {
-		newApis[item.Path] = item.Conf
+		newConfig[keyOf(item)] = item.Conf
 	}
-	s.apis.Store(&newApis)
-	s.l.InfoContext(ctx, "reloaded with new config", "config", apis)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	newApis, shadowed := merge(newConfig, s.overrides)
+	for _, k := range shadowed {
+		s.l.WarnContext(ctx, "api of the config replaced by an api of the state matching the same requests", "method", k.method, "path", k.path)
+	}
+	r, err := newRouter(newApis, s.seed, s.apis.Load())
+	if err != nil {
+		return err
+	}
+	s.config = newConfig
+	s.apis.Store(r)
+	s.clients.update(ctx, valueOr(apis.Clients, nil))
+	s.l.InfoContext(ctx, "reloaded with new config", "apis", len(apis.Apis), "overrides", len(s.overrides))
+	s.l.DebugContext(ctx, "new config", "config", apis)
+	return nil
+}
+
+// loadState restores the apis persisted in the state store.
+func (s *srv) loadState(ctx context.Context) error {
+	apis, err := s.state.Load()
+	if err != nil {
+		return err
+	}
+	apis.Normalize()
+	if err := apis.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	newOverrides := map[apiKey]api.ConfigureAPI{}
+	for _, item := range apis.Apis {
+		newOverrides[keyOf(item)] = item.Conf
+	}
+	newApis, shadowed := merge(s.config, newOverrides)
+	for _, k := range shadowed {
+		s.l.WarnContext(ctx, "api of the config replaced by an api of the state matching the same requests", "method", k.method, "path", k.path)
+	}
+	r, err := newRouter(newApis, s.seed, s.apis.Load())
+	if err != nil {
+		return err
+	}
+	s.overrides = newOverrides
+	s.apis.Store(r)
+	s.l.InfoContext(ctx, "restored apis from state", "path", s.state.Path(), "count", len(apis.Apis))
 	return nil
 }
 
+// saveState writes all the overrides to the state store.
+func (s *srv) saveState(overrides map[apiKey]api.ConfigureAPI) error {
+	return s.state.Save(api.ParamsAPI{Apis: items(overrides)})
+}
+
+// WithConfigSchema serves the JSON schema of the config.
+func WithConfigSchema(schema map[string]any) Option {
+	return func(s *srv) {
+		s.configSchema = schema
+	}
+}
+
+func (s *srv) ReportReload(ctx context.Context, source string, err error) {
+	st := *s.reloadStatus.Load()
+	now := time.Now()
+	st.Source = source
+	st.Attempts += 1
+	st.LastAttempt = &now
+	st.Success = err == nil
+	st.Error = nil
+	st.InvalidParameters = nil
+	if err != nil {
+		st.Failures += 1
+		msg := err.Error()
+		st.Error = &msg
+		st.InvalidParameters = api.InvalidParametersFromError(err)
+	} else {
+		st.LastSuccess = &now
+	}
+	s.reloadStatus.Store(&st)
+}
+
+func (s *srv) GetReloadStatus(c *gin.Context) {
+	c.PureJSON(http.StatusOK, s.reloadStatus.Load())
+}
+
+func (s *srv) GetLogLevels(c *gin.Context) {
+	if s.logLevels == nil {
+		c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: "Log levels can't be changed"})
+		return
+	}
+	c.PureJSON(http.StatusOK, s.logLevels.Levels())
+}
+
+func (s *srv) SetLogLevels(c *gin.Context) {
+	if s.logLevels == nil {
+		c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: "Log levels can't be changed"})
+		return
+	}
+	req := api.LogLevels{}
+	if err := c.Bind(&req); err != nil {
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
+		return
+	}
+	if err := s.logLevels.SetLevels(req); err != nil {
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
+		return
+	}
+	s.l.InfoContext(c.Request.Context(), "changed log levels", "level", valueOr(req.Level, ""), "components", valueOr(req.Components, nil))
+	c.PureJSON(http.StatusOK, s.logLevels.Levels())
+}
+
+func (s *srv) GetConfigSchema(c *gin.Context) {
+	if s.configSchema == nil {
+		c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: "No config schema available"})
+		return
+	}
+	c.PureJSON(http.StatusOK, s.configSchema)
+}
+
+func (s *srv) GetStats(c *gin.Context) {
+	c.PureJSON(http.StatusOK, s.apis.Load().stats())
+}
+
 func (s *srv) Home(c *gin.Context) {
 	host, _ := os.Hostname()
 	c.PureJSON(http.StatusOK, api.HomeResponse{
@@ -57,39 +246,166 @@ func (s *srv) Home(c *gin.Context) {
 }
 
 func (s *srv) ParamsApi(c *gin.Context) {
-	apis := *s.apis.Load()
+	out := api.ParamsAPI{Apis: items(s.apis.Load().apis)}
+	if clients := s.clients.list(); len(clients) > 0 {
+		out.Clients = &clients
+	}
+	c.PureJSON(http.StatusOK, out)
+}
 
-	var keys []string
-	for k := range apis {
-		keys = append(keys, k)
+func (s *srv) AdminListApis(c *gin.Context) {
+	s.ParamsApi(c)
+}
+
+// NoRoute serves what can't be expressed in the openapi spec: nested paths and apis served under the api prefix.
+func (s *srv) NoRoute(c *gin.Context) {
+	if c.Writer.Written() {
+		// A middleware already served the request (e.g. `/metrics`)
+		return
 	}
-	sort.Strings(keys)
-	out := api.ParamsAPI{
-		Apis: []api.ConfigureAPIItem{},
+	if s.serveConfigureApi(c) {
+		return
 	}
-	for _, k := range keys {
-		out.Apis = append(out.Apis, api.ConfigureAPIItem{Conf: apis[k], Path: k})
+	s.ServeDataPlane(c)
+}
+
+// ServeControlPlane serves the control endpoints which aren't in the openapi spec and nothing else, it's used on a separate listener.
+func (s *srv) ServeControlPlane(c *gin.Context) {
+	if c.Writer.Written() {
+		return
 	}
-	c.PureJSON(http.StatusOK, out)
+	if s.serveConfigureApi(c) {
+		return
+	}
+	c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: fmt.Sprintf("No route for: %s %s", c.Request.Method, c.Request.URL.Path)})
+}
+
+// serveConfigureApi configures and resets apis with nested paths, it returns false if the request isn't one to configure an api.
+func (s *srv) serveConfigureApi(c *gin.Context) bool {
+	reqPath := c.Request.URL.Path
+	if path, ok := strings.CutPrefix(reqPath, adminApisPrefix); ok && c.Request.Method == http.MethodDelete {
+		if s.runControlMiddlewares(c) {
+			s.AdminResetApi(c, path, api.AdminResetApiParams{Method: queryMethod(c)})
+		}
+		return true
+	}
+	if c.Request.Method != http.MethodPost {
+		return false
+	}
+	if path, ok := strings.CutPrefix(reqPath, adminApisPrefix); ok {
+		if s.runControlMiddlewares(c) {
+			s.AdminConfigureApi(c, path, api.AdminConfigureApiParams{Method: queryMethod(c)})
+		}
+		return true
+	}
+	if path, ok := strings.CutPrefix(reqPath, dynamicPrefix); ok {
+		if s.runControlMiddlewares(c) {
+			s.ConfigureApi(c, path, api.ConfigureApiParams{Method: queryMethod(c)})
+		}
+		return true
+	}
+	return false
+}
+
+// runControlMiddlewares returns false if a middleware aborted the request.
+func (s *srv) runControlMiddlewares(c *gin.Context) bool {
+	for _, middleware := range s.controlMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeDataPlane serves the dynamic apis and nothing else, it's used when control endpoints are on a separate listener.
+func (s *srv) ServeDataPlane(c *gin.Context) {
+	if c.Writer.Written() {
+		return
+	}
+	reqPath := c.Request.URL.Path
+	method := c.Request.Method
+	if path, ok := strings.CutPrefix(reqPath, dynamicPrefix); ok && method == http.MethodGet {
+		s.GetApi(c, path)
+		return
+	}
+	if path, ok := strings.CutPrefix(reqPath, s.apiPrefix); ok {
+		s.serveApi(c, method, path)
+		return
+	}
+	c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: fmt.Sprintf("No route for: %s %s", method, reqPath)})
+}
+
+func queryMethod(c *gin.Context) *string {
+	if m, ok := c.GetQuery("method"); ok {
+		return &m
+	}
+	return nil
 }
 
 func (s *srv) GetApi(c *gin.Context, path string) {
-	entry, exists := (*s.apis.Load())[path]
-	if !exists {
+	s.serveApi(c, http.MethodGet, path)
+}
+
+func (s *srv) serveApi(c *gin.Context, method string, path string) {
+	rt, params, allowed := s.apis.Load().match(method, path)
+	if rt == nil && len(allowed) > 0 {
+		c.Header("Allow", strings.Join(allowed, ", "))
+		c.PureJSON(http.StatusMethodNotAllowed, api.ErrorResponse{Status: http.StatusMethodNotAllowed, Details: fmt.Sprintf("No api for method %s at: %s", method, path)})
+		return
+	}
+	if rt == nil {
 		c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: fmt.Sprintf("No such api at: %s", path)})
 		return
 	}
+	b, variant := rt.behaviourFor(c.Request)
+	m := newRecorder(c, rt, b, variant)
+	defer m.done()
+	denied, claims := b.auth.check(c.Request, time.Now())
+	if denied != 0 {
+		m.request(denied, "auth")
+		m.fault(faultAuth)
+	}
+	switch denied {
+	case http.StatusUnauthorized:
+		if challenge := b.auth.challenge(); challenge != "" {
+			c.Header("WWW-Authenticate", challenge)
+		}
+		c.PureJSON(denied, api.ErrorResponse{Status: float32(denied), Details: "Missing or invalid credentials"})
+		return
+	case http.StatusForbidden:
+		c.PureJSON(denied, api.ErrorResponse{Status: float32(denied), Details: "Token doesn't have the expected claims"})
+		return
+	}
+	if !b.rateLimit.allow(c, time.Now()) {
+		m.request(http.StatusTooManyRequests, "rate_limit")
+		m.fault(faultRateLimit)
+		c.PureJSON(http.StatusTooManyRequests, api.ErrorResponse{Status: http.StatusTooManyRequests, Details: fmt.Sprintf("Rate limit exceeded for: %s", path)})
+		return
+	}
+	if !b.limit.acquire(c.Request.Context()) {
+		m.request(b.limit.status, "concurrency")
+		m.fault(faultConcurrency)
+		c.PureJSON(b.limit.status, api.ErrorResponse{Status: float32(b.limit.status), Details: fmt.Sprintf("Too many requests in flight for: %s", path)})
+		return
+	}
+	defer b.limit.release()
+	entry := b.conf
+	elapsed := time.Since(s.start)
 	latency := time.Duration(0)
 	if entry.Latency != nil { // .MaxMillis != 0 {
-		latency = time.Duration(s.rand.Intn(entry.Latency.MaxMillis-entry.Latency.MinMillis)+entry.Latency.MinMillis) * time.Millisecond
+		latency = time.Duration(b.rand.Intn(entry.Latency.MaxMillis-entry.Latency.MinMillis)+entry.Latency.MinMillis) * time.Millisecond
+		latency = time.Duration(float64(latency) * scheduleFactor(entry.Latency.Schedule, elapsed))
 	}
+	m.latency(latency)
 	if latency != 0 {
+		m.fault(faultLatency)
 		time.Sleep(latency)
 	}
 	callStatus := http.StatusOK
 	var calls []api.CallOutcome
 	for _, call := range entry.Call {
-		outcome := s.call(c.Request.Context(), call)
+		outcome := s.call(c.Request.Context(), call, m.call)
 		// The worst status from children calls defines the status of type 'inherit'
 		if !call.IgnoreStatus && outcome.Status > callStatus {
 			callStatus = outcome.Status
@@ -97,23 +413,20 @@ func (s *srv) GetApi(c *gin.Context, path string) {
 		calls = append(calls, outcome)
 	}
 
-	// Default to the status of the children
-	status := callStatus
-	n := s.rand.Intn(100000)
-	for _, v := range entry.Statuses {
-		if n < v.Ratio {
-			if v.Code == "inherit" {
-				status = callStatus
-			} else {
-				status, _ = strconv.Atoi(v.Code)
-			}
-			break
-		}
-		n -= v.Ratio
+	status, chosen, injected := b.status(callStatus, b.rand.Intn(api.MaxRatio), elapsed)
+	m.request(status, chosen)
+	if injected && status >= http.StatusBadRequest {
+		m.fault(faultStatus)
 	}
 
+	body := &strings.Builder{}
+	if err := b.body.Execute(body, api.BodyData{Path: path, Params: params, Claims: claims}); err != nil {
+		s.l.ErrorContext(c.Request.Context(), "failed to render body", "path", rt.path, "error", err)
+		c.PureJSON(http.StatusInternalServerError, api.ErrorResponse{Status: http.StatusInternalServerError, Details: fmt.Sprintf("Failed to render body: %s", err.Error())})
+		return
+	}
 	out := api.APIResponse{
-		Body:          entry.Body,
+		Body:          body.String(),
 		LatencyMillis: int(latency.Milliseconds()),
 		Status:        status,
 		Calls:         calls,
@@ -121,7 +434,15 @@ func (s *srv) GetApi(c *gin.Context, path string) {
 	c.PureJSON(out.Status, out)
 }
 
-func (s *srv) ConfigureApi(c *gin.Context, path string) {
+func (s *srv) ConfigureApi(c *gin.Context, path string, params api.ConfigureApiParams) {
+	s.configureApi(c, path, params.Method)
+}
+
+func (s *srv) AdminConfigureApi(c *gin.Context, path string, params api.AdminConfigureApiParams) {
+	s.configureApi(c, path, params.Method)
+}
+
+func (s *srv) configureApi(c *gin.Context, path string, method *string) {
 	ctx := c.Request.Context()
 	req := api.ConfigureAPI{}
 	err := c.Bind(&req)
@@ -131,27 +452,110 @@ func (s *srv) ConfigureApi(c *gin.Context, path string) {
 		return
 	}
 	req.Normalize()
+	item := api.ConfigureAPIItem{Conf: req, Path: path, Method: method}
+	item.Normalize()
+	if err := (&api_errors.MultiValidationError{}).
+		AddRootedAt(api.ValidatePath(path), "path").
+		AddRootedAt(api.ValidateMethod(item.Method), "method").
+		OrNil(); err != nil {
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
+		return
+	}
+	key := keyOf(item)
 
-	oldApi := s.apis.Load()
-	_, exists := (*oldApi)[path]
-	if exists {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldApi := s.apis.Load().apis
+	if other, ok := conflict(oldApi, key); ok {
+		err := (&api_errors.MultiValidationError{}).AddRootedAt(fmt.Sprintf("matches the same requests as the existing api '%s', use this path to change it", other.path), "path")
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
+		return
+	}
+	_, exists := oldApi[key]
+	newApi := map[apiKey]api.ConfigureAPI{}
+	maps.Copy(newApi, oldApi)
+	newApi[key] = req
+	r, err := newRouter(newApi, s.seed, s.apis.Load())
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
+		return
+	}
+	if s.state != nil {
+		newOverrides := maps.Clone(s.overrides)
+		newOverrides[key] = req
+		if err := s.saveState(newOverrides); err != nil {
+			s.l.ErrorContext(ctx, "failed to persist api to state", "path", path, "error", err)
+			c.PureJSON(http.StatusInternalServerError, api.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Details: fmt.Sprintf("Failed to persist api: %s", err.Error()),
+			})
+			return
+		}
+		s.overrides = newOverrides
+	} else if exists {
 		s.l.InfoContext(ctx, "overriding existing API, this will not be persisted across reloads of the config and restarts")
 	}
-	newApi := map[string]api.ConfigureAPI{}
-	maps.Copy(newApi, *oldApi)
-	newApi[path] = req
-	if !s.apis.CompareAndSwap(oldApi, &newApi) {
-		c.PureJSON(http.StatusConflict, api.ErrorResponse{
-			Status:  http.StatusConflict,
-			Details: "Concurrent updates to api list",
-		})
+	s.apis.Store(r)
+
+	c.PureJSON(http.StatusOK, item)
+}
+
+// AdminResetApi removes an api configured at runtime, the apis of the config it replaced are served again.
+func (s *srv) AdminResetApi(c *gin.Context, path string, params api.AdminResetApiParams) {
+	ctx := c.Request.Context()
+	item := api.ConfigureAPIItem{Path: path, Method: params.Method}
+	item.Normalize()
+	if err := (&api_errors.MultiValidationError{}).
+		AddRootedAt(api.ValidatePath(path), "path").
+		AddRootedAt(api.ValidateMethod(item.Method), "method").
+		OrNil(); err != nil {
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
 		return
 	}
+	key := keyOf(item)
 
-	c.PureJSON(http.StatusOK, api.ConfigureAPIItem{
-		Conf: req,
-		Path: path,
-	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldApi := s.apis.Load().apis
+	conf, exists := oldApi[key]
+	configured, inConfig := s.config[key]
+	_, overridden := s.overrides[key]
+	// Without a state store runtime apis aren't tracked, they are the ones which differ from the config
+	if !exists || (!overridden && inConfig && reflect.DeepEqual(configured, conf)) {
+		c.PureJSON(http.StatusNotFound, api.ErrorResponse{Status: http.StatusNotFound, Details: fmt.Sprintf("No api configured at runtime for: %s %s", key.method, path)})
+		return
+	}
+	newApi := maps.Clone(oldApi)
+	delete(newApi, key)
+	restored := map[apiKey]api.ConfigureAPI{}
+	for k, conf := range s.config {
+		if k.matchKey() == key.matchKey() {
+			newApi[k] = conf
+			restored[k] = conf
+		}
+	}
+	r, err := newRouter(newApi, s.seed, s.apis.Load())
+	if err != nil {
+		c.PureJSON(http.StatusBadRequest, api.BadRequestResponse(err))
+		return
+	}
+	if overridden {
+		newOverrides := maps.Clone(s.overrides)
+		delete(newOverrides, key)
+		if err := s.saveState(newOverrides); err != nil {
+			s.l.ErrorContext(ctx, "failed to remove api from state", "path", path, "error", err)
+			c.PureJSON(http.StatusInternalServerError, api.ErrorResponse{
+				Status:  http.StatusInternalServerError,
+				Details: fmt.Sprintf("Failed to persist api: %s", err.Error()),
+			})
+			return
+		}
+		s.overrides = newOverrides
+	}
+	s.apis.Store(r)
+	s.l.InfoContext(ctx, "reset api to the config", "method", key.method, "path", path, "restored", len(restored))
+
+	c.PureJSON(http.StatusOK, api.ParamsAPI{Apis: items(restored)})
 }
 
 func (s *srv) Health(c *gin.Context) {
@@ -170,26 +574,42 @@ func (s *srv) DegradeReady(c *gin.Context) {
 	degradeHealth(c, &s.readyStatus)
 }
 
-func (s *srv) call(ctx context.Context, call api.CallDef) api.CallOutcome {
+// call gets the url of call, record is called with the outcome once it's done.
+func (s *srv) call(ctx context.Context, call api.CallDef, record func(url string, status int, result string, d time.Duration)) api.CallOutcome {
 	outcome := api.CallOutcome{
 		Url: call.Url,
 	}
-	ctx, span := otel.Tracer("serverImpl").Start(ctx, "service-call")
+	ctx, span := otel.Tracer("serverImpl").Start(ctx, "service-call", trace.WithSpanKind(trace.SpanKindClient))
 	defer span.End()
-	span.SetAttributes(attribute.Key("url").String(call.Url))
+	span.SetAttributes(attribute.Key("url").String(call.Url), semconv.URLFull(call.Url), semconv.HTTPMethodKey.String(http.MethodGet))
 	ctx = httptrace.WithClientTrace(ctx, otelhttptrace.NewClientTrace(ctx))
+	start := time.Now()
+	result := "ok"
+	defer func() {
+		span.SetAttributes(semconv.HTTPStatusCode(outcome.Status), attribute.String("result", result))
+	}()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, call.Url, nil)
 	if err != nil {
 		s.l.ErrorContext(ctx, "failed to create request", "error", err)
+		span.SetStatus(codes.Error, err.Error())
 		outcome.Status = http.StatusInternalServerError
+		result = "network_error"
 	} else {
+		// Without this the trace breaks at every hop unless something else adds the headers
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			outcome.Status = http.StatusInternalServerError
+			result = "network_error"
 		} else {
-			span.SetStatus(codes.Ok, fmt.Sprintf("got http status: %d", resp.StatusCode))
 			outcome.Status = resp.StatusCode
+			if resp.StatusCode >= http.StatusBadRequest {
+				result = "http_error"
+				span.SetStatus(codes.Error, fmt.Sprintf("got http status: %d", resp.StatusCode))
+			} else {
+				span.SetStatus(codes.Ok, fmt.Sprintf("got http status: %d", resp.StatusCode))
+			}
 			if !call.TrimBody {
 				b, _ := io.ReadAll(resp.Body)
 				sb := string(b)
@@ -198,6 +618,7 @@ func (s *srv) call(ctx context.Context, call api.CallDef) api.CallOutcome {
 			_ = resp.Body.Close()
 		}
 	}
+	record(call.Url, outcome.Status, result, time.Since(start))
 	return outcome
 }
 
@@ -222,16 +643,34 @@ func degradeHealth(c *gin.Context, status *atomic.Int32) {
 	}
 }
 
-func NewServerImpl(l *slog.Logger, seed int64) api.ServerInterface {
+func NewServerImpl(l *slog.Logger, seed int64, opts ...Option) api.ServerInterface {
 	s := &srv{
 		l:            l.WithGroup("api-server"),
 		healthStatus: atomic.Int32{},
 		readyStatus:  atomic.Int32{},
-		apis:         atomic.Pointer[map[string]api.ConfigureAPI]{},
-		rand:         rand.New(rand.NewSource(seed)),
+		apis:         atomic.Pointer[router]{},
+		seed:         seed,
+		config:       map[apiKey]api.ConfigureAPI{},
+		overrides:    map[apiKey]api.ConfigureAPI{},
+		apiPrefix:    dynamicPrefix,
+		start:        time.Now(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.l.Info("random sources of apis derived from seed, use -seed to reproduce this run", "seed", seed)
 	s.healthStatus.Store(http.StatusOK)
 	s.readyStatus.Store(http.StatusOK)
-	s.apis.Store(&map[string]api.ConfigureAPI{})
+	s.clients = &clients{l: s.l, srv: s, running: map[string]*client{}}
+	empty, _ := newRouter(map[apiKey]api.ConfigureAPI{}, s.seed, nil)
+	s.apis.Store(empty)
+	s.reloadStatus.Store(&api.ReloadStatus{})
+	if s.state != nil {
+		if err := s.loadState(context.Background()); err != nil {
+			// Saving would overwrite the apis of the file with only the ones configured from now on
+			s.l.Error("state loading failed, server will start without persisted apis and won't persist apis until the state file is fixed and the server restarted", "path", s.state.Path(), "error", err)
+			s.state = nil
+		}
+	}
 	return s
 }
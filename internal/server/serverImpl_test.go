@@ -0,0 +1,48 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestNoRouteAfterMiddleware checks that requests served by a middleware, like `/metrics` by the observability one, aren't also answered with a 404.
+func TestNoRouteAfterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServerImpl(slog.New(slog.NewTextHandler(io.Discard, nil)), 0).(*srv)
+	metrics := func(c *gin.Context) {
+		if c.Request.URL.Path == "/metrics" {
+			c.String(http.StatusOK, "# metrics\n")
+		}
+	}
+	tests := []struct {
+		name    string
+		noRoute gin.HandlerFunc
+	}{
+		{name: "no route", noRoute: s.NoRoute},
+		{name: "data plane", noRoute: s.ServeDataPlane},
+		{name: "control plane", noRoute: s.ServeControlPlane},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.Use(metrics)
+			engine.NoRoute(tt.noRoute)
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if w.Code != http.StatusOK || w.Body.String() != "# metrics\n" {
+				t.Fatalf("expected only the metrics, got %d: %q", w.Code, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nope", nil))
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("expected a 404 for other paths, got %d: %q", w.Code, w.Body.String())
+			}
+		})
+	}
+}
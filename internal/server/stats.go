@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencySamples is the number of requests latency percentiles are computed on.
+	latencySamples = 1024
+	// recentRequests is the number of requests kept to show the last ones.
+	recentRequests = 20
+)
+
+// stats are live statistics of the requests to a behaviour, they are reset when the config of the behaviour changes.
+type stats struct {
+	since    time.Time
+	inFlight atomic.Int64
+
+	mu       sync.Mutex
+	requests int64
+	statuses map[int]int64
+	entries  map[string]int64
+	// durations and recent are ring buffers, next is where the next request goes.
+	durations []time.Duration
+	recent    []api.RequestSummary
+	next      int
+}
+
+func newStats(now time.Time) *stats {
+	return &stats{
+		since:     now,
+		statuses:  map[int]int64{},
+		entries:   map[string]int64{},
+		durations: make([]time.Duration, 0, latencySamples),
+		recent:    make([]api.RequestSummary, 0, recentRequests),
+	}
+}
+
+// record adds a request which got a response, d is how long it took.
+func (s *stats) record(summary api.RequestSummary, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.statuses[summary.Status]++
+	if summary.Chosen != nil {
+		s.entries[*summary.Chosen]++
+	}
+	if len(s.durations) < latencySamples {
+		s.durations = append(s.durations, d)
+	} else {
+		s.durations[s.next%latencySamples] = d
+	}
+	if len(s.recent) < recentRequests {
+		s.recent = append(s.recent, summary)
+	} else {
+		s.recent[s.next%recentRequests] = summary
+	}
+	s.next++
+}
+
+// snapshot returns the statistics compared with conf.
+func (s *stats) snapshot(conf api.ConfigureAPI) api.ApiStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := api.ApiStats{
+		Since:    s.since,
+		Requests: int(s.requests),
+		InFlight: int(s.inFlight.Load()),
+		Statuses: []api.StatusCount{},
+		Entries:  []api.EntryStats{},
+		Latency:  api.NewLatencyStats(slices.Clone(s.durations)),
+		Recent:   []api.RequestSummary{},
+	}
+	var codes []int
+	for code := range s.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		out.Statuses = append(out.Statuses, api.StatusCount{Status: code, Count: int(s.statuses[code]), ObservedRatio: s.ratio(s.statuses[code])})
+	}
+	seen := map[string]bool{}
+	for i, st := range conf.Statuses {
+		st := st
+		entry := fmt.Sprintf("statuses[%d]", i)
+		seen[entry] = true
+		e := api.EntryStats{Entry: entry, Code: &st.Code, Count: int(s.entries[entry]), ObservedRatio: s.ratio(s.entries[entry])}
+		if st.Pattern == nil {
+			e.ConfiguredRatio = &st.Ratio
+		}
+		out.Entries = append(out.Entries, e)
+	}
+	var others []string
+	for entry := range s.entries {
+		if !seen[entry] {
+			others = append(others, entry)
+		}
+	}
+	sort.Strings(others)
+	for _, entry := range others {
+		out.Entries = append(out.Entries, api.EntryStats{Entry: entry, Count: int(s.entries[entry]), ObservedRatio: s.ratio(s.entries[entry])})
+	}
+	for i := 1; i <= len(s.recent); i++ {
+		out.Recent = append(out.Recent, s.recent[(s.next-i)%recentRequests])
+	}
+	return out
+}
+
+// ratio is the share of requests in the unit of the ratio of statuses.
+func (s *stats) ratio(count int64) int {
+	if s.requests == 0 {
+		return 0
+	}
+	return int(math.Round(float64(count) * api.MaxRatio / float64(s.requests)))
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"strconv"
+	"time"
+)
+
+// status picks the status of a request, callStatus is the status of the children calls.
+// roll is a random number in [0, MaxRatio) used by the statuses with a ratio.
+// chosen is the entry of the config which gave the status (e.g. `statuses[1]`), it's empty when no entry was picked.
+// injected is false when the status is the one of the children calls.
+func (b *behaviour) status(callStatus int, roll int, elapsed time.Duration) (status int, chosen string, injected bool) {
+	n := b.requests.Add(1)
+	if b.conf.Sequence != nil {
+		seq := *b.conf.Sequence
+		i := (n - 1) % int64(len(seq))
+		status, injected = codeOf(seq[i], callStatus)
+		return status, fmt.Sprintf("sequence[%d]", i), injected
+	}
+	for i, v := range b.conf.Statuses {
+		if v.Pattern != nil && matchesPattern(v.Pattern, n) && scheduleFactor(v.Schedule, elapsed) > 0 {
+			status, injected = codeOf(v.Code, callStatus)
+			return status, fmt.Sprintf("statuses[%d]", i), injected
+		}
+	}
+	for i, v := range b.conf.Statuses {
+		if v.Pattern != nil {
+			continue
+		}
+		ratio := int(float64(v.Ratio) * scheduleFactor(v.Schedule, elapsed))
+		if roll < ratio {
+			status, injected = codeOf(v.Code, callStatus)
+			return status, fmt.Sprintf("statuses[%d]", i), injected
+		}
+		roll -= ratio
+	}
+	// Default to the status of the children
+	return callStatus, "", false
+}
+
+// matchesPattern checks if the nth request of an api (starting at 1) gets the status of the pattern.
+func matchesPattern(p *api.PatternDef, n int64) bool {
+	if p.First != nil && n > int64(*p.First) {
+		return false
+	}
+	if p.Every != nil {
+		every := int64(*p.Every)
+		count := int64(valueOr(p.Count, 1))
+		if (n-1)%every < every-count {
+			return false
+		}
+	}
+	return true
+}
+
+func codeOf(code string, callStatus int) (int, bool) {
+	if code == "inherit" {
+		return callStatus, false
+	}
+	status, _ := strconv.Atoi(code)
+	return status, true
+}
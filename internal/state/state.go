@@ -0,0 +1,107 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lahabana/api-play/pkg/api"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the apis configured at runtime in a file so that they survive reloads and restarts.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load returns the apis saved in the state file, a missing file is the same as an empty state.
+func (s *Store) Load() (api.ParamsAPI, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apis := api.ParamsAPI{Apis: []api.ConfigureAPIItem{}}
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return apis, nil
+		}
+		return apis, err
+	}
+	if err := yaml.Unmarshal(b, &apis); err != nil {
+		return apis, err
+	}
+	return apis, nil
+}
+
+// Save replaces the content of the state file, the write is atomic so a crash never leaves a partial file.
+func (s *Store) Save(apis api.ParamsAPI) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := marshal(apis)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// marshal writes apis as yaml with only the fields which are set.
+// It goes through json as the generated types only omit unset fields in json.
+func marshal(apis api.ParamsAPI) ([]byte, error) {
+	b, err := json.Marshal(apis)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+	blockStyle(doc, true)
+	return yaml.Marshal(doc)
+}
+
+// blockStyle removes the json style of nodes, strings are only quoted when they'd be read as another type.
+// Empty lists below the top level (like `call` and `statuses`) are removed as they are the default when loading.
+func blockStyle(n *yaml.Node, top bool) {
+	n.Style = 0
+	if n.Kind == yaml.MappingNode && !top {
+		var content []*yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if v := n.Content[i+1]; v.Kind == yaml.SequenceNode && len(v.Content) == 0 {
+				continue
+			}
+			content = append(content, n.Content[i], n.Content[i+1])
+		}
+		n.Content = content
+	}
+	for _, c := range n.Content {
+		blockStyle(c, top && n.Kind == yaml.DocumentNode)
+	}
+}
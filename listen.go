@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lahabana/api-play/internal/auth"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const adminTokenEnv = "API_PLAY_ADMIN_TOKEN"
+
+type authConf struct {
+	tokenFile     string
+	basicAuthFile string
+	clientNames   string
+	readOnly      bool
+}
+
+func (a authConf) load() (auth.Config, error) {
+	res := auth.Config{ReadOnly: a.readOnly, Token: os.Getenv(adminTokenEnv)}
+	if a.tokenFile != "" {
+		token, err := auth.ReadToken(a.tokenFile)
+		if err != nil {
+			return res, err
+		}
+		res.Token = token
+	}
+	if a.basicAuthFile != "" {
+		users, err := auth.ReadUsers(a.basicAuthFile)
+		if err != nil {
+			return res, err
+		}
+		res.Users = users
+	}
+	for _, name := range strings.Split(a.clientNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			res.ClientNames = append(res.ClientNames, name)
+		}
+	}
+	return res, nil
+}
+
+type tlsConf struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+}
+
+// load returns the TLS config of the control listener or nil if TLS is not enabled.
+func (t tlsConf) load() (*tls.Config, error) {
+	if t.certFile == "" && t.keyFile == "" {
+		if t.clientCAFile != "" {
+			return nil, errors.New("admin-tls-client-ca-file requires admin-tls-cert-file and admin-tls-key-file")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	res := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if t.clientCAFile != "" {
+		b, err := os.ReadFile(t.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificate found in %s", t.clientCAFile)
+		}
+		res.ClientCAs = pool
+		// Clients without certificates can still use read endpoints or other authentication methods
+		res.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return res, nil
+}
+
+// defaultAddr is the address gin listens on by default.
+func defaultAddr() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":8080"
+}
+
+func run(engine *gin.Engine, addr string, tlsConfig *tls.Config) error {
+	if tlsConfig == nil {
+		return engine.Run(addr)
+	}
+	srv := &http.Server{Addr: addr, Handler: engine.Handler(), TLSConfig: tlsConfig}
+	return srv.ListenAndServeTLS("", "")
+}
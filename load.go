@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/lahabana/api-play/internal/load"
+	"github.com/lahabana/otel-gin/pkg/observability"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// loadCommand sends traffic to urls and prints a JSON report of the latencies and statuses.
+func loadCommand(args []string) int {
+	conf := load.Conf{Headers: http.Header{}}
+	var metricsAddr, otlpMetrics string
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s load [flags] url...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Float64Var(&conf.Rps, "rps", 0, "Requests per second sent in total across all urls (between 0.001 and 1000000), when 0 each worker sends requests as fast as it can")
+	fs.IntVar(&conf.Concurrency, "concurrency", 10, "Number of workers sending requests, with rps it's the maximum number of requests in flight")
+	fs.DurationVar(&conf.Duration, "duration", 10*time.Second, "How long to send traffic for")
+	fs.DurationVar(&conf.Timeout, "timeout", 5*time.Second, "Timeout of each request")
+	fs.StringVar(&conf.Method, "method", http.MethodGet, "The http method of the requests")
+	fs.StringVar(&conf.Body, "body", "", "The body of the requests")
+	fs.Func("header", "A header to add to the requests as `name: value` (can be repeated)", func(s string) error {
+		name, value, ok := strings.Cut(s, ":")
+		if !ok {
+			return errors.New("expected name: value")
+		}
+		conf.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		return nil
+	})
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "If set, metrics of the requests sent are served on /metrics at this address (e.g. :9090)")
+	fs.StringVar(&otlpMetrics, "otlp-metrics", "", "whether or not we should export metrics using otlp (options: http,grpc)")
+	_ = fs.Parse(args)
+	conf.Urls = fs.Args()
+	if err := conf.Validate(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if metricsAddr != "" || otlpMetrics != "" {
+		// Init logs to stdout, only warnings are shown to keep the report parseable
+		if _, err := observability.Init(ctx, "api-play", slog.LevelWarn, observability.OTLPFormat(otlpMetrics), observability.OTLPNone); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "failed to serve metrics: %s\n", err)
+			}
+		}()
+	}
+	writeJSON(os.Stdout, load.Run(ctx, conf))
+	return 0
+}
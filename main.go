@@ -5,53 +5,239 @@ import (
 	"flag"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/lahabana/api-play/internal/accesslog"
+	"github.com/lahabana/api-play/internal/auth"
+	"github.com/lahabana/api-play/internal/logging"
+	"github.com/lahabana/api-play/internal/propagation"
 	"github.com/lahabana/api-play/internal/reload"
 	"github.com/lahabana/api-play/internal/server"
+	"github.com/lahabana/api-play/internal/state"
 	"github.com/lahabana/api-play/pkg/api"
 	api_errors "github.com/lahabana/api-play/pkg/errors"
 	"github.com/lahabana/otel-gin/pkg/observability"
-	"log/slog"
+	"go.opentelemetry.io/otel"
+	"os"
+	"strings"
 	"time"
 )
 
 //go:generate go run github.com/deepmap/oapi-codegen/v2/cmd/oapi-codegen@v2.0.0 -config openapi.cfg.yaml openapi.yaml
 
 type Conf struct {
-	configFile  string
-	seed        int64
-	otlpMetrics string
-	otlpTraces  string
+	configFile      string
+	configDir       string
+	configUrl       string
+	pollPeriod      time.Duration
+	pollJitter      time.Duration
+	stateFile       string
+	apiPrefix       string
+	adminAddr       string
+	auth            authConf
+	tls             tlsConf
+	seed            int64
+	accessLog       string
+	accessLogFormat string
+	propagators     string
+	logLevel        string
+	logFormat       string
+	logLevels       string
+	otlpMetrics     string
+	otlpTraces      string
+}
+
+// subCommands are run instead of the server when their name is the first argument.
+var subCommands = map[string]func(args []string) int{
+	"validate": validateCommand,
+	"schema":   schemaCommand,
+	"load":     loadCommand,
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subCommands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	conf := Conf{}
 	flag.StringVar(&conf.configFile, "config-file", "", "A yaml config of the apis")
-	flag.Int64Var(&conf.seed, "seed", time.Now().UnixMicro(), "Seed for random generators")
+	flag.StringVar(&conf.configDir, "config-dir", "", "A directory of yaml or json configs of the apis, all files are merged together (can't be used with config-file)")
+	flag.StringVar(&conf.configUrl, "config-url", "", "A url to poll to get the yaml config of the apis (can't be used with config-file or config-dir)")
+	flag.DurationVar(&conf.pollPeriod, "config-poll-interval", 30*time.Second, "How often to poll config-url, it must be greater than 0")
+	flag.DurationVar(&conf.pollJitter, "config-poll-jitter", 5*time.Second, "Maximum random duration added to config-poll-interval to avoid all instances polling at the same time")
+	flag.StringVar(&conf.stateFile, "state-file", "", "A file where apis configured through the API are persisted, they take precedence over the ones in the config-file")
+	flag.StringVar(&conf.apiPrefix, "api-prefix", "/api/dynamic", "The path under which dynamic apis are served with any method, use / to serve them at the root (configure them with /admin/apis)")
+	flag.StringVar(&conf.adminAddr, "admin-addr", "", "If set, control endpoints (config, health toggles, introspection) are served on this address (e.g. :8081) and the main port only serves the dynamic apis")
+	flag.StringVar(&conf.auth.tokenFile, "admin-token-file", "", "A file with a bearer token required for mutating control endpoints (can also be set with the env var "+adminTokenEnv+")")
+	flag.StringVar(&conf.auth.basicAuthFile, "admin-basic-auth-file", "", "A file of user:password lines allowed to use mutating control endpoints with basic auth")
+	flag.StringVar(&conf.auth.clientNames, "admin-client-names", "", "A comma separated list of mTLS client identities (common name, DNS or URI SAN) allowed to use mutating control endpoints, requires admin-tls-client-ca-file")
+	flag.BoolVar(&conf.auth.readOnly, "read-only", false, "Reject all mutating control endpoints")
+	flag.StringVar(&conf.tls.certFile, "admin-tls-cert-file", "", "A certificate to serve TLS on the listener of the control endpoints (the admin listener or the main one when admin-addr isn't set)")
+	flag.StringVar(&conf.tls.keyFile, "admin-tls-key-file", "", "The key of admin-tls-cert-file")
+	flag.StringVar(&conf.tls.clientCAFile, "admin-tls-client-ca-file", "", "A CA to verify client certificates, used with admin-client-names")
+	flag.Int64Var(&conf.seed, "seed", time.Now().UnixMicro(), "Seed the random sources of the apis are derived from, use the one logged at startup to reproduce a run")
+	flag.StringVar(&conf.accessLog, "access-log", "", "Where to write an access log line per request: stdout or a file (disabled by default)")
+	flag.StringVar(&conf.accessLogFormat, "access-log-format", accesslog.FormatJSON, "The format of the access log: json or common")
+	flag.StringVar(&conf.propagators, "propagators", "tracecontext,baggage", "A comma separated list of the formats of the trace context to extract from requests and inject in calls (options: "+strings.Join(propagation.Names(), ",")+",none)")
+	flag.StringVar(&conf.logLevel, "log-level", "info", "The level of the logs: debug, info, warn or error")
+	flag.StringVar(&conf.logFormat, "log-format", logging.FormatText, "The format of the logs: text or json")
+	flag.StringVar(&conf.logLevels, "log-levels", "", "A comma separated list of component=level to override log-level for some of the components api-server, config-loader and gin-observability (e.g. config-loader=debug,api-server=warn), they can be changed at runtime with /admin/log-levels except gin-observability whose logs are also always text")
 	flag.StringVar(&conf.otlpMetrics, "otlp-metrics", "", "whether or not we should export metrics using otlp (options: http,grpc)")
 	flag.StringVar(&conf.otlpTraces, "otlp-traces", "", "whether or not we should export traces using otlp (options: http,grpc)")
 	flag.Parse()
-	obs, err := observability.Init(ctx, "api-play", slog.LevelDebug, observability.OTLPFormat(conf.otlpMetrics), observability.OTLPFormat(conf.otlpTraces))
+	sources := 0
+	for _, s := range []string{conf.configFile, conf.configDir, conf.configUrl} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		panic("only one of config-file, config-dir and config-url can be used")
+	}
+	if conf.configUrl != "" && conf.pollPeriod <= 0 {
+		panic("config-poll-interval must be greater than 0")
+	}
+	if conf.pollJitter < 0 {
+		panic("config-poll-jitter must not be negative")
+	}
+	logLevel, err := logging.ParseLevel(conf.logLevel)
+	if err != nil {
+		panic(err)
+	}
+	componentLevels, err := logging.ParseLevels(conf.logLevels)
+	if err != nil {
+		panic(err)
+	}
+	logs, err := logging.New(os.Stdout, conf.logFormat, logLevel, componentLevels, []string{"api-server", "config-loader", "gin-observability"})
+	if err != nil {
+		panic(err)
+	}
+	// The logger of the observability middleware is created by Init so its level can only be set at startup
+	obs, err := observability.Init(ctx, "api-play", logs.Level("gin-observability"), observability.OTLPFormat(conf.otlpMetrics), observability.OTLPFormat(conf.otlpTraces))
+	if err != nil {
+		panic(err)
+	}
+	propagator, err := propagation.Parse(conf.propagators)
+	if err != nil {
+		panic(err)
+	}
+	// This replaces the propagator set by Init, it must happen before the observability middleware is created
+	otel.SetTextMapPropagator(propagator)
+	configSchema, err := api.ConfigJSONSchema(openapiSpec)
 	if err != nil {
 		panic(err)
 	}
-	serverInstance := server.NewServerImpl(obs.Logger(), time.Now().UnixMicro())
-	if reloader, ok := serverInstance.(api.Reloader); ok && conf.configFile != "" {
-		reload.BackgroundConfigReload(ctx, obs.Logger().With("name", "config-loader"), conf.configFile, reloader)
+	serverOpts := []server.Option{server.WithConfigSchema(configSchema), server.WithApiPrefix(conf.apiPrefix), server.WithLogLevels(logs)}
+	if conf.stateFile != "" {
+		serverOpts = append(serverOpts, server.WithStateStore(state.NewStore(conf.stateFile)))
+	}
+	authConfig, err := conf.auth.load()
+	if err != nil {
+		panic(err)
+	}
+	controlTLS, err := conf.tls.load()
+	if err != nil {
+		panic(err)
+	}
+	if len(authConfig.ClientNames) > 0 && conf.tls.clientCAFile == "" {
+		panic("admin-client-names requires admin-tls-client-ca-file")
+	}
+	controlMiddlewares := []api.MiddlewareFunc{auth.Middleware(authConfig)}
+	serverOpts = append(serverOpts, server.WithControlMiddlewares(controlMiddlewares...))
+	serverInstance := server.NewServerImpl(logs.Logger("api-server"), conf.seed, serverOpts...)
+	if reloader, ok := serverInstance.(api.Reloader); ok {
+		switch {
+		case conf.configFile != "":
+			reload.BackgroundConfigReload(ctx, logs.Logger("config-loader"), conf.configFile, reloader)
+		case conf.configDir != "":
+			reload.BackgroundConfigDirReload(ctx, logs.Logger("config-loader"), conf.configDir, reloader)
+		case conf.configUrl != "":
+			reload.BackgroundConfigPoll(ctx, logs.Logger("config-loader"), conf.configUrl, conf.pollPeriod, conf.pollJitter, reloader)
+		}
 	}
 
-	engine := gin.New()
 	binding.Validator = &localValidator{delegate: binding.Validator}
-	engine.Use(gin.Recovery(), obs.Middleware())
-	api.RegisterHandlersWithOptions(engine, serverInstance, api.GinServerOptions{})
-	err = engine.Run()
+	middlewares := []gin.HandlerFunc{gin.Recovery(), obs.Middleware()}
+	if conf.accessLog != "" {
+		w, err := accesslog.Open(conf.accessLog)
+		if err != nil {
+			panic(err)
+		}
+		accessLogMiddleware, err := accesslog.Middleware(w, conf.accessLogFormat)
+		if err != nil {
+			panic(err)
+		}
+		middlewares = append(middlewares, accessLogMiddleware)
+	}
+	engine := gin.New()
+	engine.Use(middlewares...)
+	errs := make(chan error, 2)
+	if conf.adminAddr != "" {
+		adminEngine := gin.New()
+		adminEngine.Use(middlewares...)
+		registerControlPlane(adminEngine, serverInstance, controlMiddlewares)
+		registerDataPlane(engine, serverInstance)
+		go func() {
+			errs <- run(adminEngine, conf.adminAddr, controlTLS)
+		}()
+		go func() {
+			errs <- run(engine, defaultAddr(), nil)
+		}()
+	} else {
+		registerAll(engine, serverInstance, controlMiddlewares)
+		go func() {
+			errs <- run(engine, defaultAddr(), controlTLS)
+		}()
+	}
+	err = <-errs
 	cancel()
 	if err != nil {
 		panic(err)
 	}
 }
 
+// registerAll registers all the routes of the openapi spec and serves the dynamic apis on the same engine.
+func registerAll(engine *gin.Engine, serverInstance api.ServerInterface, middlewares []api.MiddlewareFunc) {
+	api.RegisterHandlersWithOptions(engine, serverInstance, api.GinServerOptions{Middlewares: middlewares})
+	if noRouteHandler, ok := serverInstance.(api.NoRouteHandler); ok {
+		engine.NoRoute(noRouteHandler.NoRoute)
+	}
+}
+
+// registerControlPlane registers the routes of the openapi spec without serving the dynamic apis, it's used on the admin listener.
+func registerControlPlane(engine *gin.Engine, serverInstance api.ServerInterface, middlewares []api.MiddlewareFunc) {
+	controlPlaneHandler, ok := serverInstance.(api.ControlPlaneHandler)
+	if !ok {
+		panic("the server can't serve the control plane on its own listener")
+	}
+	api.RegisterHandlersWithOptions(engine, controlPlaneOnly{ServerInterface: serverInstance, notFound: controlPlaneHandler.ServeControlPlane}, api.GinServerOptions{Middlewares: middlewares})
+	engine.NoRoute(controlPlaneHandler.ServeControlPlane)
+}
+
+// controlPlaneOnly doesn't serve the dynamic apis of the openapi spec route `GET /api/dynamic/{path}`.
+type controlPlaneOnly struct {
+	api.ServerInterface
+	notFound gin.HandlerFunc
+}
+
+func (s controlPlaneOnly) GetApi(c *gin.Context, _ string) {
+	s.notFound(c)
+}
+
+// registerDataPlane registers the routes serving traffic: the dynamic apis, home and health checks.
+func registerDataPlane(engine *gin.Engine, serverInstance api.ServerInterface) {
+	wrapper := api.ServerInterfaceWrapper{Handler: serverInstance}
+	engine.GET("/", wrapper.Home)
+	engine.GET("/health", wrapper.Health)
+	engine.GET("/ready", wrapper.Ready)
+	dataPlaneHandler, ok := serverInstance.(api.DataPlaneHandler)
+	if !ok {
+		panic("the server can't serve the data plane on its own listener")
+	}
+	engine.NoRoute(dataPlaneHandler.ServeDataPlane)
+}
+
 type localValidator struct {
 	delegate binding.StructValidator
 }
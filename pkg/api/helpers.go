@@ -4,23 +4,33 @@ import (
 	"errors"
 	"fmt"
 	api_errors "github.com/lahabana/api-play/pkg/errors"
+	"log/slog"
+	"math"
 	"net/http"
-	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
-var rePath = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_-]+$")
-
 const (
 	MaxRatio = 100_000
+	// MinClientRps and MaxClientRps bound the rate of background clients, they keep the interval between calls a valid duration.
+	MinClientRps = 0.001
+	MaxClientRps = 10_000
 )
 
-func ValidatePath(path string) error {
-	if !rePath.MatchString(path) {
-		return fmt.Errorf("'%s' doesn't match re: %s", path, rePath.String())
-	}
-	return nil
-}
+const (
+	RateLimitKeyGlobal   = "global"
+	RateLimitKeyClientIP = "client_ip"
+	RateLimitKeyHeader   = "header"
+)
+
+// RateLimitKeys are what requests can be rate limited by.
+var RateLimitKeys = []string{RateLimitKeyGlobal, RateLimitKeyClientIP, RateLimitKeyHeader}
+
+// Methods are the http methods dynamic apis can respond to.
+var Methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
 
 func (a *Health) Validate() error {
 	r := &api_errors.MultiValidationError{}
@@ -30,10 +40,73 @@ func (a *Health) Validate() error {
 	return r.OrNil()
 }
 
+func (a *LogLevels) Validate() error {
+	r := &api_errors.MultiValidationError{}
+	if a.Level != nil {
+		r = r.AddRootedAt(validateLogLevel(*a.Level), "level")
+	}
+	if a.Components != nil {
+		var components []string
+		for component := range *a.Components {
+			components = append(components, component)
+		}
+		slices.Sort(components)
+		for _, component := range components {
+			r = r.AddRootedAt(validateLogLevel((*a.Components)[component]), "components", component)
+		}
+	}
+	return r.OrNil()
+}
+
+func validateLogLevel(level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("'%s' is not one of: debug, info, warn, error", level)
+	}
+	return nil
+}
+
 func (a *ParamsAPI) Validate() error {
 	r := &api_errors.MultiValidationError{}
+	definedAt := map[string]int{}
 	for i, api := range a.Apis {
 		r = r.AddRootedAt(api.Validate(), "apis", i)
+		key := api.MatchKey()
+		if other, exists := definedAt[key]; exists {
+			r = r.AddRootedAt(fmt.Sprintf("matches the same requests as apis[%d]", other), "apis", i, "path")
+		} else {
+			definedAt[key] = i
+		}
+	}
+	if a.Clients != nil {
+		clientAt := map[string]int{}
+		for i, client := range *a.Clients {
+			r = r.AddRootedAt(client.Validate(), "clients", i)
+			if other, exists := clientAt[client.Name]; exists {
+				r = r.AddRootedAt(fmt.Sprintf("has the same name as clients[%d]", other), "clients", i, "name")
+			} else {
+				clientAt[client.Name] = i
+			}
+		}
+	}
+	return r.OrNil()
+}
+
+func (a *ClientDef) Validate() error {
+	r := &api_errors.MultiValidationError{}
+	if a.Name == "" {
+		r = r.AddRootedAt("can't be empty", "name")
+	}
+	if a.Rps < MinClientRps {
+		r = r.AddRootedAt(fmt.Sprintf("can't be less than %g", MinClientRps), "rps")
+	} else if a.Rps > MaxClientRps {
+		r = r.AddRootedAt(fmt.Sprintf("can't be greater than %d", MaxClientRps), "rps")
+	}
+	if len(a.Call) == 0 {
+		r = r.AddRootedAt("can't be empty", "call")
+	}
+	for i, call := range a.Call {
+		r = r.AddRootedAt(call.Validate(), "call", i)
 	}
 	return r.OrNil()
 }
@@ -47,14 +120,37 @@ func (a *ParamsAPI) Normalize() {
 func (a *ConfigureAPIItem) Validate() error {
 	r := &api_errors.MultiValidationError{}
 	return r.AddRootedAt(ValidatePath(a.Path), "path").
+		AddRootedAt(ValidateMethod(a.Method), "method").
 		AddRootedAt(a.Conf.Validate(), "conf").
 		OrNil()
 }
 
 func (a *ConfigureAPIItem) Normalize() {
+	a.Method = NormalizeMethod(a.Method)
 	a.Conf.Normalize()
 }
 
+// MatchKey is the same for apis which match the same requests.
+func (a *ConfigureAPIItem) MatchKey() string {
+	return *NormalizeMethod(a.Method) + " " + CanonicalPath(a.Path)
+}
+
+// NormalizeMethod returns the upper case method or GET if it's not set.
+func NormalizeMethod(method *string) *string {
+	res := http.MethodGet
+	if method != nil && *method != "" {
+		res = strings.ToUpper(*method)
+	}
+	return &res
+}
+
+func ValidateMethod(method *string) error {
+	if method == nil || slices.Contains(Methods, *method) {
+		return nil
+	}
+	return fmt.Errorf("'%s' is not one of: %s", *method, strings.Join(Methods, ", "))
+}
+
 func (a CallDef) Validate() error {
 	r := &api_errors.MultiValidationError{}
 	if a.Url == "" {
@@ -65,17 +161,47 @@ func (a CallDef) Validate() error {
 
 func (a StatusDef) Validate() error {
 	merr := &api_errors.MultiValidationError{}
-	if a.Code != "inherit" {
-		code, err := strconv.Atoi(a.Code)
-		if err != nil {
-			merr = merr.AddRootedAt("is not a number or `inherit`", "code")
-		} else if code <= 0 {
-			merr = merr.AddRootedAt("must be greater than 0", "code")
-		}
-	}
-	if a.Ratio <= 0 || a.Ratio > 100000 {
+	merr = merr.AddRootedAt(validateCode(a.Code), "code")
+	if a.Pattern != nil {
+		merr = merr.AddRootedAt(a.Pattern.Validate(), "pattern")
+	} else if a.Ratio <= 0 || a.Ratio > 100000 {
 		merr = merr.AddRootedAt("must be between 1 and 100,000", "ratio")
 	}
+	return merr.AddRootedAt(a.Schedule.Validate(), "schedule").OrNil()
+}
+
+func validateCode(code string) error {
+	if code == "inherit" {
+		return nil
+	}
+	c, err := strconv.Atoi(code)
+	if err != nil {
+		return errors.New("is not a number or `inherit`")
+	}
+	if c <= 0 {
+		return errors.New("must be greater than 0")
+	}
+	return nil
+}
+
+func (a *PatternDef) Validate() error {
+	merr := &api_errors.MultiValidationError{}
+	if a.First == nil && a.Every == nil {
+		return merr.AddRootedAt("must have at least one of first or every").OrNil()
+	}
+	if a.First != nil && *a.First <= 0 {
+		merr = merr.AddRootedAt("must be greater than 0", "first")
+	}
+	if a.Every != nil && *a.Every <= 0 {
+		merr = merr.AddRootedAt("must be greater than 0", "every")
+	}
+	if a.Count != nil {
+		if a.Every == nil {
+			merr = merr.AddRootedAt("requires every", "count")
+		} else if *a.Count <= 0 || *a.Count > *a.Every {
+			merr = merr.AddRootedAt("must be between 1 and every", "count")
+		}
+	}
 	return merr.OrNil()
 }
 
@@ -93,11 +219,91 @@ func (a *LatencyDef) Validate() error {
 	if a.MaxMillis < 0 {
 		merr = merr.AddRootedAt("can't be negative", "max_millis")
 	}
+	return merr.AddRootedAt(a.Schedule.Validate(), "schedule").OrNil()
+}
+
+func (a *ScheduleDef) Validate() error {
+	if a == nil {
+		return nil
+	}
+	merr := &api_errors.MultiValidationError{}
+	if valueOr(a.StartAfterSeconds, 0) < 0 {
+		merr = merr.AddRootedAt("can't be negative", "start_after_seconds")
+	}
+	if a.EverySeconds != nil && *a.EverySeconds <= 0 {
+		merr = merr.AddRootedAt("must be greater than 0", "every_seconds")
+	}
+	if a.DurationSeconds != nil && *a.DurationSeconds <= 0 {
+		merr = merr.AddRootedAt("must be greater than 0", "duration_seconds")
+	}
+	if a.RampSeconds != nil && *a.RampSeconds <= 0 {
+		merr = merr.AddRootedAt("must be greater than 0", "ramp_seconds")
+	}
+	if a.StopAfterSeconds != nil && *a.StopAfterSeconds <= valueOr(a.StartAfterSeconds, 0) {
+		merr = merr.AddRootedAt("must be greater than start_after_seconds", "stop_after_seconds")
+	}
+	if a.DurationSeconds != nil {
+		if a.EverySeconds == nil {
+			merr = merr.AddRootedAt("requires every_seconds", "duration_seconds")
+		} else if *a.DurationSeconds > *a.EverySeconds {
+			merr = merr.AddRootedAt("can't be greater than every_seconds", "duration_seconds")
+		}
+	}
+	return merr.OrNil()
+}
+
+func valueOr[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func (a *ConcurrencyDef) Validate() error {
+	if a == nil {
+		return nil
+	}
+	merr := &api_errors.MultiValidationError{}
+	if a.MaxInFlight < 1 {
+		merr = merr.AddRootedAt("must be greater than 0", "max_in_flight")
+	}
+	if a.QueueSize != nil && *a.QueueSize < 0 {
+		merr = merr.AddRootedAt("can't be negative", "queue_size")
+	}
+	if a.QueueTimeoutMillis != nil && *a.QueueTimeoutMillis < 0 {
+		merr = merr.AddRootedAt("can't be negative", "queue_timeout_millis")
+	}
+	if a.OverloadStatus != nil && (*a.OverloadStatus < 100 || *a.OverloadStatus > 599) {
+		merr = merr.AddRootedAt("must be between 100 and 599", "overload_status")
+	}
+	return merr.OrNil()
+}
+
+func (a *RateLimitDef) Validate() error {
+	if a == nil {
+		return nil
+	}
+	merr := &api_errors.MultiValidationError{}
+	if a.Rate <= 0 {
+		merr = merr.AddRootedAt("must be greater than 0", "rate")
+	}
+	if a.Burst != nil && *a.Burst < 1 {
+		merr = merr.AddRootedAt("must be greater than 0", "burst")
+	}
+	if a.Key != nil && !slices.Contains(RateLimitKeys, *a.Key) {
+		merr = merr.AddRootedAt(fmt.Sprintf("'%s' is not one of: %s", *a.Key, strings.Join(RateLimitKeys, ", ")), "key")
+	}
+	if a.Key != nil && *a.Key == RateLimitKeyHeader && (a.Header == nil || *a.Header == "") {
+		merr = merr.AddRootedAt("is required when key is 'header'", "header")
+	}
 	return merr.OrNil()
 }
 
 func (a *ConfigureAPI) Validate() error {
 	merr := &api_errors.MultiValidationError{}
+	if err := ValidateBodyTemplate(a.Body); err != nil {
+		merr = merr.AddRootedAt(fmt.Sprintf("invalid template: %s", err.Error()), "body")
+	}
 	merr = merr.AddRootedAt(a.Latency.Validate(), "latency")
 	for i, c := range a.Call {
 		merr = merr.AddRootedAt(c.Validate(), "call", i)
@@ -106,7 +312,9 @@ func (a *ConfigureAPI) Validate() error {
 	allStatus := map[string]struct{}{}
 	for i, s := range a.Statuses {
 		merr = merr.AddRootedAt(s.Validate(), "statuses", i)
-		total += s.Ratio
+		if s.Pattern == nil {
+			total += s.Ratio
+		}
 		if s.Code == "inherit" && len(a.Call) == 0 {
 			merr = merr.AddRootedAt("can't use status with code 'inherit' when no call set", "statuses")
 		}
@@ -118,6 +326,28 @@ func (a *ConfigureAPI) Validate() error {
 	if total > MaxRatio {
 		merr = merr.AddRootedAt(fmt.Sprintf("sum of ratios can't be greater than %d", MaxRatio), "statuses")
 	}
+	if a.Sequence != nil {
+		if len(a.Statuses) > 0 {
+			merr = merr.AddRootedAt("can't be set with statuses", "sequence")
+		}
+		if len(*a.Sequence) == 0 {
+			merr = merr.AddRootedAt("can't be empty", "sequence")
+		}
+		for i, code := range *a.Sequence {
+			merr = merr.AddRootedAt(validateCode(code), "sequence", i)
+			if code == "inherit" && len(a.Call) == 0 {
+				merr = merr.AddRootedAt("can't use code 'inherit' when no call set", "sequence", i)
+			}
+		}
+	}
+	merr = merr.AddRootedAt(a.Auth.Validate(), "auth")
+	merr = merr.AddRootedAt(a.Concurrency.Validate(), "concurrency")
+	merr = merr.AddRootedAt(a.RateLimit.Validate(), "rate_limit")
+	if a.Variants != nil {
+		for i, v := range *a.Variants {
+			merr = merr.AddRootedAt(v.Validate(), "variants", i)
+		}
+	}
 	return merr.OrNil()
 }
 
@@ -128,20 +358,105 @@ func (a *ConfigureAPI) Normalize() {
 	if a.Statuses == nil {
 		a.Statuses = []StatusDef{}
 	}
+	if a.Variants != nil {
+		for i := range *a.Variants {
+			(*a.Variants)[i].Normalize()
+		}
+	}
+}
+
+func (a *VariantDef) Validate() error {
+	merr := &api_errors.MultiValidationError{}
+	merr = merr.AddRootedAt(a.Match.Validate(), "match").
+		AddRootedAt(a.Conf.Validate(), "conf")
+	if a.Conf.Variants != nil {
+		merr = merr.AddRootedAt("variants can't be nested", "conf", "variants")
+	}
+	return merr.OrNil()
+}
+
+func (a *VariantDef) Normalize() {
+	a.Match.Normalize()
+	a.Conf.Normalize()
+}
+
+func (a *MatchDef) Validate() error {
+	r := &api_errors.MultiValidationError{}
+	return r.AddRootedAt(ValidateMethod(a.Method), "method").OrNil()
+}
+
+func (a *MatchDef) Normalize() {
+	if a.Method != nil {
+		a.Method = NormalizeMethod(a.Method)
+	}
+}
+
+func (a *AuthDef) Validate() error {
+	if a == nil {
+		return nil
+	}
+	merr := &api_errors.MultiValidationError{}
+	if a.Key == nil && a.Basic == nil && a.Jwt == nil {
+		merr = merr.AddRootedAt("must have at least one of key, basic or jwt")
+	}
+	if a.Key != nil && len(a.Key.Keys) == 0 {
+		merr = merr.AddRootedAt("can't be empty", "key", "keys")
+	}
+	if a.Basic != nil && len(a.Basic.Users) == 0 {
+		merr = merr.AddRootedAt("can't be empty", "basic", "users")
+	}
+	if a.Jwt != nil {
+		merr = merr.AddRootedAt(a.Jwt.Validate(), "jwt")
+	}
+	return merr.OrNil()
+}
+
+func (a *JWTAuthDef) Validate() error {
+	merr := &api_errors.MultiValidationError{}
+	if (a.Jwks == nil) == (a.JwksFile == nil) {
+		merr = merr.AddRootedAt("must have exactly one of jwks or jwks_file")
+	}
+	return merr.OrNil()
+}
+
+// InvalidParametersFromError returns the invalid parameters of a validation error or nil for other errors.
+func InvalidParametersFromError(err error) *[]InvalidParameters {
+	t := &api_errors.MultiValidationError{}
+	if !errors.As(err, &t) {
+		return nil
+	}
+	var valErrors []InvalidParameters
+	for _, e := range t.Errors {
+		valErrors = append(valErrors, InvalidParameters{Field: e.Path(), Reason: e.Message})
+	}
+	return &valErrors
 }
 
 func BadRequestResponse(err error) ErrorResponse {
 	res := ErrorResponse{Status: http.StatusBadRequest}
-	t := &api_errors.MultiValidationError{}
-	if errors.As(err, &t) {
+	if valErrors := InvalidParametersFromError(err); valErrors != nil {
 		res.Details = "Validation errors"
-		var valErrors []InvalidParameters
-		for _, e := range t.Errors {
-			valErrors = append(valErrors, InvalidParameters{Field: e.Path(), Reason: e.Message})
-		}
-		res.InvalidParameters = &valErrors
+		res.InvalidParameters = valErrors
 	} else {
 		res.Details = fmt.Sprintf("Failed with error: %s", err.Error())
 	}
 	return res
 }
+
+// NewLatencyStats computes the percentiles of durations, it sorts durations in place.
+func NewLatencyStats(durations []time.Duration) LatencyStats {
+	out := LatencyStats{Samples: len(durations)}
+	if len(durations) == 0 {
+		return out
+	}
+	slices.Sort(durations)
+	percentile := func(p float64) int {
+		i := int(math.Ceil(p*float64(len(durations)))) - 1
+		return int(durations[max(i, 0)].Milliseconds())
+	}
+	out.P50Millis = percentile(0.5)
+	out.P90Millis = percentile(0.9)
+	out.P99Millis = percentile(0.99)
+	out.MaxMillis = int(durations[len(durations)-1].Milliseconds())
+	return out
+}
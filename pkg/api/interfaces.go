@@ -1,6 +1,9 @@
 package api
 
-import "context"
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+)
 
 type Reloader interface {
 	Reload(ctx context.Context, apis ParamsAPI) error
@@ -9,3 +12,23 @@ type Reloader interface {
 type Normalizer interface {
 	Normalize()
 }
+
+// ReloadReporter is notified of the outcome of every attempt to load the config from a config source.
+type ReloadReporter interface {
+	ReportReload(ctx context.Context, source string, err error)
+}
+
+// NoRouteHandler serves the requests which don't match any route of the openapi spec.
+type NoRouteHandler interface {
+	NoRoute(c *gin.Context)
+}
+
+// ControlPlaneHandler serves the control endpoints which aren't in the openapi spec without any of the dynamic apis.
+type ControlPlaneHandler interface {
+	ServeControlPlane(c *gin.Context)
+}
+
+// DataPlaneHandler serves the dynamic apis without any of the control endpoints.
+type DataPlaneHandler interface {
+	ServeDataPlane(c *gin.Context)
+}
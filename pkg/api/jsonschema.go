@@ -0,0 +1,254 @@
+package api
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+const (
+	openapiRefPrefix    = "#/components/schemas/"
+	jsonSchemaRefPrefix = "#/definitions/"
+	jsonSchemaDraft     = "http://json-schema.org/draft-07/schema#"
+)
+
+// configRequired overrides the required fields of the openapi spec for the config file.
+// Some fields are required in the API but have a default when loading the config (see Normalize).
+var configRequired = map[string][]string{
+	"ParamsAPI":        {},
+	"ConfigureAPIItem": {"path", "conf"},
+	"ConfigureAPI":     {},
+	"LatencyDef":       {},
+	"StatusDef":        {"code"},
+	"CallDef":          {"url"},
+	"ClientDef":        {"name", "rps", "call"},
+	"VariantDef":       {"match", "conf"},
+}
+
+// configRules adds the rules enforced by the Validate functions which are not in the openapi spec.
+var configRules = map[string]func(def map[string]any){
+	"ConfigureAPIItem": func(def map[string]any) {
+		property(def, "path")["pattern"] = rePath.String()
+		property(def, "method")["enum"] = stringsToAny(Methods)
+	},
+	"ConfigureAPI": func(def map[string]any) {
+		property(def, "sequence")["minItems"] = 1
+		// Like the code of statuses, codes of the sequence are usually written as numbers in yaml
+		property(def, "sequence")["items"] = map[string]any{"type": []any{"string", "integer"}, "pattern": "^(inherit|[1-9][0-9]*)$", "minimum": 1}
+		appendDescription(property(def, "statuses"), fmt.Sprintf("The sum of the ratios can't be greater than %d and a code can't be used twice.", MaxRatio))
+		// A status with code `inherit` requires at least one call
+		def["if"] = map[string]any{
+			"required": []any{"statuses"},
+			"properties": map[string]any{
+				"statuses": map[string]any{
+					"contains": map[string]any{
+						"required":   []any{"code"},
+						"properties": map[string]any{"code": map[string]any{"const": "inherit"}},
+					},
+				},
+			},
+		}
+		def["then"] = map[string]any{
+			"required":   []any{"call"},
+			"properties": map[string]any{"call": map[string]any{"minItems": 1}},
+		}
+	},
+	"LatencyDef": func(def map[string]any) {
+		property(def, "min_millis")["minimum"] = 0
+		property(def, "max_millis")["minimum"] = 0
+		appendDescription(def, "max_millis must be greater or equal to min_millis.")
+	},
+	"StatusDef": func(def map[string]any) {
+		code := property(def, "code")
+		// In yaml codes are usually written as numbers which are decoded in the string field
+		code["type"] = []any{"string", "integer"}
+		code["pattern"] = "^(inherit|[1-9][0-9]*)$"
+		code["minimum"] = 1
+		property(def, "ratio")["minimum"] = 1
+		property(def, "ratio")["maximum"] = MaxRatio
+		// The ratio is only required without a pattern
+		def["anyOf"] = []any{
+			map[string]any{"required": []any{"ratio"}},
+			map[string]any{"required": []any{"pattern"}},
+		}
+	},
+	"PatternDef": func(def map[string]any) {
+		property(def, "first")["minimum"] = 1
+		property(def, "every")["minimum"] = 1
+		property(def, "count")["minimum"] = 1
+		def["anyOf"] = []any{
+			map[string]any{"required": []any{"first"}},
+			map[string]any{"required": []any{"every"}},
+		}
+		def["dependencies"] = map[string]any{"count": []any{"every"}}
+		appendDescription(def, "count can't be greater than every.")
+	},
+	"ScheduleDef": func(def map[string]any) {
+		property(def, "start_after_seconds")["minimum"] = 0
+		property(def, "stop_after_seconds")["minimum"] = 1
+		property(def, "every_seconds")["minimum"] = 1
+		property(def, "duration_seconds")["minimum"] = 1
+		property(def, "ramp_seconds")["minimum"] = 1
+		def["dependencies"] = map[string]any{"duration_seconds": []any{"every_seconds"}}
+		appendDescription(def, "stop_after_seconds must be greater than start_after_seconds and duration_seconds can't be greater than every_seconds.")
+	},
+	"MatchDef": func(def map[string]any) {
+		property(def, "method")["enum"] = stringsToAny(Methods)
+	},
+	"CallDef": func(def map[string]any) {
+		property(def, "url")["minLength"] = 1
+	},
+	"ClientDef": func(def map[string]any) {
+		property(def, "name")["minLength"] = 1
+		property(def, "rps")["minimum"] = MinClientRps
+		property(def, "rps")["maximum"] = MaxClientRps
+		property(def, "call")["minItems"] = 1
+	},
+	"ConcurrencyDef": func(def map[string]any) {
+		property(def, "max_in_flight")["minimum"] = 1
+		property(def, "queue_size")["minimum"] = 0
+		property(def, "queue_timeout_millis")["minimum"] = 0
+		property(def, "overload_status")["minimum"] = 100
+		property(def, "overload_status")["maximum"] = 599
+	},
+	"RateLimitDef": func(def map[string]any) {
+		property(def, "rate")["exclusiveMinimum"] = 0
+		property(def, "burst")["minimum"] = 1
+		property(def, "key")["enum"] = stringsToAny(RateLimitKeys)
+		def["if"] = map[string]any{
+			"required":   []any{"key"},
+			"properties": map[string]any{"key": map[string]any{"const": RateLimitKeyHeader}},
+		}
+		def["then"] = map[string]any{"required": []any{"header"}}
+	},
+	"AuthDef": func(def map[string]any) {
+		def["minProperties"] = 1
+	},
+	"ApiKeyAuthDef": func(def map[string]any) {
+		property(def, "keys")["minItems"] = 1
+	},
+	"BasicAuthDef": func(def map[string]any) {
+		property(def, "users")["minProperties"] = 1
+	},
+	"JWTAuthDef": func(def map[string]any) {
+		def["oneOf"] = []any{
+			map[string]any{"required": []any{"jwks"}},
+			map[string]any{"required": []any{"jwks_file"}},
+		}
+	},
+}
+
+// ConfigJSONSchema builds a JSON schema (draft-07) of the config file from the openapi spec.
+// This is the schema of ParamsAPI with the extra rules of the Validate functions.
+func ConfigJSONSchema(openapiSpec []byte) (map[string]any, error) {
+	spec := map[string]any{}
+	if err := yaml.Unmarshal(openapiSpec, &spec); err != nil {
+		return nil, err
+	}
+	components, _ := spec["components"].(map[string]any)
+	schemas, _ := components["schemas"].(map[string]any)
+	if _, ok := schemas["ParamsAPI"]; !ok {
+		return nil, fmt.Errorf("no ParamsAPI schema in openapi spec")
+	}
+	definitions := map[string]any{}
+	toVisit := []string{"ParamsAPI"}
+	for len(toVisit) > 0 {
+		name := toVisit[0]
+		toVisit = toVisit[1:]
+		if _, exists := definitions[name]; exists {
+			continue
+		}
+		schema, ok := schemas[name].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("missing schema %s in openapi spec", name)
+		}
+		var refs []string
+		def := toJSONSchema(schema, &refs).(map[string]any)
+		if required, ok := configRequired[name]; ok {
+			if len(required) == 0 {
+				delete(def, "required")
+			} else {
+				def["required"] = stringsToAny(required)
+			}
+		}
+		if rule, ok := configRules[name]; ok {
+			rule(def)
+		}
+		definitions[name] = def
+		toVisit = append(toVisit, refs...)
+	}
+	root := map[string]any{
+		"$schema":     jsonSchemaDraft,
+		"title":       "api-play config",
+		"definitions": definitions,
+	}
+	// draft-07 ignores siblings of `$ref` so the root schema is a copy of ParamsAPI
+	for k, v := range definitions["ParamsAPI"].(map[string]any) {
+		root[k] = v
+	}
+	return root, nil
+}
+
+// toJSONSchema converts an openapi schema to a JSON schema and collects the names of the schemas it references.
+func toJSONSchema(in any, refs *[]string) any {
+	switch v := in.(type) {
+	case map[string]any:
+		out := map[string]any{}
+		for k, child := range v {
+			switch {
+			case k == "$ref":
+				name := strings.TrimPrefix(child.(string), openapiRefPrefix)
+				*refs = append(*refs, name)
+				out[k] = jsonSchemaRefPrefix + name
+			case strings.HasPrefix(k, "x-"):
+				// Extensions are only meaningful for the code generator
+			case k == "properties":
+				props := map[string]any{}
+				for name, prop := range child.(map[string]any) {
+					props[name] = toJSONSchema(prop, refs)
+				}
+				out[k] = props
+			default:
+				out[k] = toJSONSchema(child, refs)
+			}
+		}
+		if v["x-go-type"] == "int" {
+			out["type"] = "integer"
+		}
+		if _, ok := out["properties"]; ok {
+			if _, ok := out["additionalProperties"]; !ok {
+				out["additionalProperties"] = false
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i := range v {
+			out[i] = toJSONSchema(v[i], refs)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func property(def map[string]any, name string) map[string]any {
+	props := def["properties"].(map[string]any)
+	return props[name].(map[string]any)
+}
+
+func appendDescription(def map[string]any, desc string) {
+	if d, ok := def["description"].(string); ok && d != "" {
+		def["description"] = strings.TrimSpace(d) + "\n" + desc
+	} else {
+		def["description"] = desc
+	}
+}
+
+func stringsToAny(in []string) []any {
+	out := make([]any, len(in))
+	for i := range in {
+		out[i] = in[i]
+	}
+	return out
+}
@@ -6,6 +6,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/oapi-codegen/runtime"
@@ -19,6 +20,66 @@ type APIResponse struct {
 	Status        int           `json:"status"`
 }
 
+// ApiKeyAuthDef A static api key in a header or a query parameter
+type ApiKeyAuthDef struct {
+	// Header The header containing the key (default X-API-Key)
+	Header *string `json:"header,omitempty"`
+
+	// Keys The accepted keys
+	Keys []string `json:"keys"`
+
+	// Query A query parameter containing the key, it's used when the header is absent
+	Query *string `json:"query,omitempty"`
+}
+
+// ApiStats defines model for ApiStats.
+type ApiStats struct {
+	// Entries Number of requests by entry of the config which chose their status, the entries of `statuses` are always present to compare their ratio with the observed one
+	Entries []EntryStats `json:"entries"`
+
+	// InFlight Number of requests being handled
+	InFlight int `json:"in_flight" yaml:"in_flight"`
+
+	// Latency Percentiles of the duration of the last requests, including the injected latency and the calls
+	Latency LatencyStats `json:"latency"`
+	Method  string       `json:"method"`
+	Path    string       `json:"path"`
+
+	// Recent The last requests, the most recent first
+	Recent []RequestSummary `json:"recent"`
+
+	// Requests Number of requests which got a response
+	Requests int `json:"requests"`
+
+	// Since When the statistics started, they are reset when the config of this api or variant changes
+	Since time.Time `json:"since"`
+
+	// Statuses Number of requests by status sorted by status
+	Statuses []StatusCount `json:"statuses"`
+
+	// Variant The name of the variant, absent for the default behaviour of the api
+	Variant *string `json:"variant,omitempty"`
+}
+
+// AuthDef Credentials required to use the api, a request is allowed if it passes any of the configured methods.
+// Requests without valid credentials get a 401, requests with a valid token which doesn't have the expected claims get a 403
+type AuthDef struct {
+	// Basic Basic authentication
+	Basic *BasicAuthDef `json:"basic,omitempty"`
+
+	// Jwt A bearer token in the Authorization header signed with RS256, ES256 or HS256 (and their 384/512 variants)
+	Jwt *JWTAuthDef `json:"jwt,omitempty"`
+
+	// Key A static api key in a header or a query parameter
+	Key *ApiKeyAuthDef `json:"key,omitempty"`
+}
+
+// BasicAuthDef Basic authentication
+type BasicAuthDef struct {
+	// Users The passwords of the accepted users by user name
+	Users map[string]string `json:"users"`
+}
+
 // CallDef a list of urls that we'd call get on
 type CallDef struct {
 	// IgnoreStatus don't consider the status code when using `inherit`
@@ -36,25 +97,97 @@ type CallOutcome struct {
 	Url    string  `json:"url"`
 }
 
+// ClientDef Calls made in the background at a fixed rate
+type ClientDef struct {
+	// Call The calls made in order at each tick, `trim_body` and `ignore_status` have no effect
+	Call []CallDef `json:"call"`
+
+	// Name The name of the client in logs and metrics, it must be unique
+	Name string `json:"name"`
+
+	// Rps Number of times per second the calls are made, between 0.001 and 10000
+	Rps float32 `json:"rps"`
+}
+
+// ConcurrencyDef Limit of requests handled at the same time, requests over the limit wait in a queue if there's one and are rejected otherwise.
+// Requests are also rejected when they waited in the queue for longer than the queue timeout
+type ConcurrencyDef struct {
+	// MaxInFlight The maximum number of requests handled at the same time
+	MaxInFlight int `json:"max_in_flight" yaml:"max_in_flight"`
+
+	// OverloadStatus The status returned to rejected requests (default 503)
+	OverloadStatus *int `json:"overload_status,omitempty" yaml:"overload_status"`
+
+	// QueueSize The maximum number of requests waiting for a slot (default 0)
+	QueueSize *int `json:"queue_size,omitempty" yaml:"queue_size"`
+
+	// QueueTimeoutMillis How long a request can wait in the queue, 0 waits until the client gives up (default 0)
+	QueueTimeoutMillis *int `json:"queue_timeout_millis,omitempty" yaml:"queue_timeout_millis"`
+}
+
 // ConfigureAPI defines model for ConfigureAPI.
 type ConfigureAPI struct {
-	// Body The content to return in the response
+	// Auth Credentials required to use the api, a request is allowed if it passes any of the configured methods.
+	// Requests without valid credentials get a 401, requests with a valid token which doesn't have the expected claims get a 403
+	Auth *AuthDef `json:"auth,omitempty"`
+
+	// Body The content to return in the response, this is a go template where `.Params` are the parameters of the path
+	// (e.g. `{{ .Params.id }}`), `.Path` is the path of the request and `.Claims` are the claims of the JWT when using `auth.jwt`
 	Body string    `json:"body"`
 	Call []CallDef `json:"call"`
 
+	// Concurrency Limit of requests handled at the same time, requests over the limit wait in a queue if there's one and are rejected otherwise.
+	// Requests are also rejected when they waited in the queue for longer than the queue timeout
+	Concurrency *ConcurrencyDef `json:"concurrency,omitempty"`
+
 	// Latency Extra latency to pick from a uniform distribution to add to this call
 	Latency *LatencyDef `json:"latency,omitempty"`
 
+	// RateLimit A token bucket rate limit, requests over the limit get a 429 with a `Retry-After` header.
+	// Responses have `X-RateLimit-Limit`, `X-RateLimit-Remaining` and `X-RateLimit-Reset` headers
+	RateLimit *RateLimitDef `json:"rate_limit,omitempty" yaml:"rate_limit"`
+
+	// Sequence Status codes to return in order, one per request, starting over at the end of the list.
+	// Codes are numbers or `inherit`, statuses can't be set with a sequence
+	Sequence *[]string `json:"sequence,omitempty"`
+
 	// Statuses The status codes to return, it will return with the probability passed in,
 	// If the sum of the ratio of the entries doesn't add to 100000 it will complete with the status
 	// of the children calls or 200 if there were no children calls
 	Statuses []StatusDef `json:"statuses"`
+
+	// Variants Alternative behaviours of the api chosen by matching the request.
+	// The first variant that matches the request is used, if none matches the api behaves as configured outside of variants
+	Variants *[]VariantDef `json:"variants,omitempty"`
 }
 
 // ConfigureAPIItem defines model for ConfigureAPIItem.
 type ConfigureAPIItem struct {
 	Conf ConfigureAPI `json:"conf"`
-	Path string       `json:"path"`
+
+	// Method The http method the api responds to, one of GET, POST, PUT, DELETE and PATCH (default GET)
+	Method *string `json:"method,omitempty"`
+
+	// Path The path of the api, segments separated by `/` are either a literal, a `{param}` or `*` to match any segment.
+	// The last segment can be `**` to match any number of segments.
+	// When multiple apis match a request segments are compared from left to right, a literal wins over a `{param}` which wins over `*` which wins over `**`.
+	Path string `json:"path"`
+}
+
+// EntryStats defines model for EntryStats.
+type EntryStats struct {
+	// Code The code of the entry when it's in `statuses`
+	Code *string `json:"code,omitempty"`
+
+	// ConfiguredRatio The ratio of the entry when it's in `statuses` and has no pattern
+	ConfiguredRatio *int `json:"configured_ratio,omitempty" yaml:"configured_ratio"`
+	Count           int  `json:"count"`
+
+	// Entry The entry of the config (e.g. `statuses[1]`, `sequence[0]`, `rate_limit`)
+	Entry string `json:"entry"`
+
+	// ObservedRatio The share of the requests which got their status from this entry (out of 100000)
+	ObservedRatio int `json:"observed_ratio" yaml:"observed_ratio"`
 }
 
 // ErrorResponse defines model for ErrorResponse.
@@ -83,15 +216,176 @@ type InvalidParameters struct {
 	Reason string `json:"reason"`
 }
 
+// JWTAuthDef A bearer token in the Authorization header signed with RS256, ES256 or HS256 (and their 384/512 variants)
+type JWTAuthDef struct {
+	// Audiences The `aud` claim must contain at least one of these
+	Audiences *[]string `json:"audiences,omitempty"`
+
+	// Claims Claims with their exact value, keys are `.` separated paths (e.g. `realm_access.role`), a mismatch returns a 403
+	Claims *map[string]string `json:"claims,omitempty"`
+
+	// Issuer The expected `iss` claim
+	Issuer *string `json:"issuer,omitempty"`
+
+	// Jwks The keys to verify tokens with as an inline json JWKS or a single JWK
+	Jwks *string `json:"jwks,omitempty"`
+
+	// JwksFile A file containing the JWKS to verify tokens with
+	JwksFile *string `json:"jwks_file,omitempty" yaml:"jwks_file"`
+}
+
 // LatencyDef Extra latency to pick from a uniform distribution to add to this call
 type LatencyDef struct {
 	MaxMillis int `json:"max_millis" yaml:"max_millis"`
 	MinMillis int `json:"min_millis" yaml:"min_millis"`
+
+	// Schedule When the entry is active, times are in seconds since the server started.
+	// The entry is active from `start_after_seconds` until `stop_after_seconds`, with `every_seconds` it's only active
+	// for the first `duration_seconds` of each period. With `ramp_seconds` the ratio (or the latency) grows linearly
+	// from 0 to its value at the start of each active window
+	Schedule *ScheduleDef `json:"schedule,omitempty"`
+}
+
+// LatencyStats Percentiles of the duration of the last requests, including the injected latency and the calls
+type LatencyStats struct {
+	MaxMillis int `json:"max_millis" yaml:"max_millis"`
+	P50Millis int `json:"p50_millis" yaml:"p50_millis"`
+	P90Millis int `json:"p90_millis" yaml:"p90_millis"`
+	P99Millis int `json:"p99_millis" yaml:"p99_millis"`
+
+	// Samples Number of requests the percentiles are computed on
+	Samples int `json:"samples"`
+}
+
+// LogLevels defines model for LogLevels.
+type LogLevels struct {
+	// Components The level of each component (e.g. config-loader, api-server)
+	Components *map[string]string `json:"components,omitempty"`
+
+	// Level The level of the components without their own level (debug, info, warn or error)
+	Level *string `json:"level,omitempty"`
+}
+
+// MatchDef Conditions on the request, all of them must be true for the request to match
+type MatchDef struct {
+	// Body Fields of a json body with their exact value, keys are `.` separated paths (e.g. `user.tier`)
+	Body *map[string]string `json:"body,omitempty"`
+
+	// Headers Headers with their exact value, names are case insensitive
+	Headers *map[string]string `json:"headers,omitempty"`
+
+	// Method The http method of the request
+	Method *string `json:"method,omitempty"`
+
+	// Query Query parameters with their exact value
+	Query *map[string]string `json:"query,omitempty"`
 }
 
 // ParamsAPI defines model for ParamsAPI.
 type ParamsAPI struct {
 	Apis []ConfigureAPIItem `json:"apis"`
+
+	// Clients Calls made periodically by this instance to generate traffic, they are only set with the config
+	Clients *[]ClientDef `json:"clients,omitempty"`
+}
+
+// PatternDef Requests which get the status, chosen by their position instead of randomly (the first request of the api is 1).
+// Statuses with a pattern are checked in order before the ones with a ratio
+type PatternDef struct {
+	// Count The size of a burst of requests getting the status at the end of each group of `every` (default 1)
+	Count *int `json:"count,omitempty"`
+
+	// Every Requests are grouped by `every` and the last `count` of each group get the status (e.g. every 3rd request)
+	Every *int `json:"every,omitempty"`
+
+	// First Only the first requests get the status
+	First *int `json:"first,omitempty"`
+}
+
+// RateLimitDef A token bucket rate limit, requests over the limit get a 429 with a `Retry-After` header.
+// Responses have `X-RateLimit-Limit`, `X-RateLimit-Remaining` and `X-RateLimit-Reset` headers
+type RateLimitDef struct {
+	// Burst The size of the bucket (default the rate rounded up)
+	Burst *int `json:"burst,omitempty"`
+
+	// Header The header whose value requests are limited by when key is `header`
+	Header *string `json:"header,omitempty"`
+
+	// Key What requests are limited by, one of `global`, `client_ip` and `header` (default global)
+	Key *string `json:"key,omitempty"`
+
+	// Rate The number of requests per second the bucket is refilled with
+	Rate float32 `json:"rate"`
+}
+
+// ReloadStatus defines model for ReloadStatus.
+type ReloadStatus struct {
+	// Attempts Number of times the config was loaded
+	Attempts int `json:"attempts"`
+
+	// Error The error of the last attempt if it failed
+	Error *string `json:"error,omitempty"`
+
+	// Failures Number of times loading the config failed
+	Failures int `json:"failures"`
+
+	// InvalidParameters The validation errors of the last attempt if it failed validation
+	InvalidParameters *[]InvalidParameters `json:"invalid_parameters,omitempty" yaml:"invalid_parameters"`
+	LastAttempt       *time.Time           `json:"last_attempt,omitempty" yaml:"last_attempt"`
+	LastSuccess       *time.Time           `json:"last_success,omitempty" yaml:"last_success"`
+
+	// Source Where the config is loaded from (file, directory or url), empty if there's no config source
+	Source string `json:"source"`
+
+	// Success Whether the last attempt to load the config succeeded
+	Success bool `json:"success"`
+}
+
+// ScheduleDef When the entry is active, times are in seconds since the server started.
+// The entry is active from `start_after_seconds` until `stop_after_seconds`, with `every_seconds` it's only active
+// for the first `duration_seconds` of each period. With `ramp_seconds` the ratio (or the latency) grows linearly
+// RequestSummary defines model for RequestSummary.
+type RequestSummary struct {
+	// Chosen The entry of the config which chose the status, absent when it's the status of the calls
+	Chosen         *string `json:"chosen,omitempty"`
+	DurationMillis int     `json:"duration_millis" yaml:"duration_millis"`
+
+	// Faults The faults injected in the request
+	Faults *[]string `json:"faults,omitempty"`
+	Status int       `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// from 0 to its value at the start of each active window
+type ScheduleDef struct {
+	// DurationSeconds How long the entry is active in each period (default the whole period)
+	DurationSeconds *int `json:"duration_seconds,omitempty" yaml:"duration_seconds"`
+
+	// EverySeconds The period of a repeating window starting at `start_after_seconds`
+	EverySeconds *int `json:"every_seconds,omitempty" yaml:"every_seconds"`
+
+	// RampSeconds How long it takes to go from 0 to the full value at the start of each active window
+	RampSeconds *int `json:"ramp_seconds,omitempty" yaml:"ramp_seconds"`
+
+	// StartAfterSeconds When the entry starts being active (default 0)
+	StartAfterSeconds *int `json:"start_after_seconds,omitempty" yaml:"start_after_seconds"`
+
+	// StopAfterSeconds When the entry stops being active (default never)
+	StopAfterSeconds *int `json:"stop_after_seconds,omitempty" yaml:"stop_after_seconds"`
+}
+
+// Stats defines model for Stats.
+type Stats struct {
+	Apis []ApiStats `json:"apis"`
+}
+
+// StatusCount defines model for StatusCount.
+type StatusCount struct {
+	Count int `json:"count"`
+
+	// ObservedRatio The share of the requests with this status in the same unit as the ratio of `StatusDef` (out of 100000)
+	ObservedRatio int `json:"observed_ratio" yaml:"observed_ratio"`
+	Status        int `json:"status"`
 }
 
 // StatusDef defines model for StatusDef.
@@ -99,10 +393,55 @@ type StatusDef struct {
 	// Code The status code to return. `inherit` is a special key that will return whatever `call` leads to
 	Code string `json:"code"`
 
-	// Ratio The proportion of the requests out of 100k that should return this status
+	// Pattern Requests which get the status, chosen by their position instead of randomly (the first request of the api is 1).
+	// Statuses with a pattern are checked in order before the ones with a ratio
+	Pattern *PatternDef `json:"pattern,omitempty"`
+
+	// Ratio The proportion of the requests out of 100k that should return this status, it's ignored with a pattern
 	Ratio int `json:"ratio"`
+
+	// Schedule When the entry is active, times are in seconds since the server started.
+	// The entry is active from `start_after_seconds` until `stop_after_seconds`, with `every_seconds` it's only active
+	// for the first `duration_seconds` of each period. With `ramp_seconds` the ratio (or the latency) grows linearly
+	// from 0 to its value at the start of each active window
+	Schedule *ScheduleDef `json:"schedule,omitempty"`
+}
+
+// VariantDef defines model for VariantDef.
+type VariantDef struct {
+	Conf ConfigureAPI `json:"conf"`
+
+	// Match Conditions on the request, all of them must be true for the request to match
+	Match MatchDef `json:"match"`
+
+	// Name A name to identify the variant
+	Name *string `json:"name,omitempty"`
+}
+
+// Method defines model for method.
+type Method = string
+
+// AdminConfigureApiParams defines parameters for AdminConfigureApi.
+type AdminConfigureApiParams struct {
+	// Method the http method the api responds to (default GET)
+	Method *Method `form:"method,omitempty" json:"method,omitempty"`
 }
 
+// AdminResetApiParams defines parameters for AdminResetApi.
+type AdminResetApiParams struct {
+	// Method the http method the api responds to (default GET)
+	Method *Method `form:"method,omitempty" json:"method,omitempty"`
+}
+
+// ConfigureApiParams defines parameters for ConfigureApi.
+type ConfigureApiParams struct {
+	// Method the http method the api responds to (default GET)
+	Method *Method `form:"method,omitempty" json:"method,omitempty"`
+}
+
+// AdminConfigureApiJSONRequestBody defines body for AdminConfigureApi for application/json ContentType.
+type AdminConfigureApiJSONRequestBody = ConfigureAPI
+
 // ConfigureApiJSONRequestBody defines body for ConfigureApi for application/json ContentType.
 type ConfigureApiJSONRequestBody = ConfigureAPI
 
@@ -112,12 +451,39 @@ type DegradeHealthJSONRequestBody = Health
 // DegradeReadyJSONRequestBody defines body for DegradeReady for application/json ContentType.
 type DegradeReadyJSONRequestBody = Health
 
+// SetLogLevelsJSONRequestBody defines body for SetLogLevels for application/json ContentType.
+type SetLogLevelsJSONRequestBody = LogLevels
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 	// home
 	// (GET /)
 	Home(c *gin.Context)
 	// list all apis registered
+	// (GET /admin/apis)
+	AdminListApis(c *gin.Context)
+	// reset an api to the config
+	// (DELETE /admin/apis/{path})
+	AdminResetApi(c *gin.Context, path string, params AdminResetApiParams)
+	// set api params
+	// (POST /admin/apis/{path})
+	AdminConfigureApi(c *gin.Context, path string, params AdminConfigureApiParams)
+	// levels of the loggers
+	// (GET /admin/log-levels)
+	GetLogLevels(c *gin.Context)
+	// change the levels of the loggers
+	// (POST /admin/log-levels)
+	SetLogLevels(c *gin.Context)
+	// status of the config loading
+	// (GET /admin/reload)
+	GetReloadStatus(c *gin.Context)
+	// JSON schema of the config
+	// (GET /admin/schema)
+	GetConfigSchema(c *gin.Context)
+	// statistics of the apis
+	// (GET /admin/stats)
+	GetStats(c *gin.Context)
+	// list all apis registered
 	// (GET /api/dynamic)
 	ParamsApi(c *gin.Context)
 	// hello
@@ -125,7 +491,7 @@ type ServerInterface interface {
 	GetApi(c *gin.Context, path string)
 	// set api params
 	// (POST /api/dynamic/{path})
-	ConfigureApi(c *gin.Context, path string)
+	ConfigureApi(c *gin.Context, path string, params ConfigureApiParams)
 	// healthcheck
 	// (GET /health)
 	Health(c *gin.Context)
@@ -162,6 +528,154 @@ func (siw *ServerInterfaceWrapper) Home(c *gin.Context) {
 	siw.Handler.Home(c)
 }
 
+// AdminListApis operation middleware
+func (siw *ServerInterfaceWrapper) AdminListApis(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.AdminListApis(c)
+}
+
+// AdminResetApi operation middleware
+func (siw *ServerInterfaceWrapper) AdminResetApi(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "path" -------------
+	var path string
+
+	err = runtime.BindStyledParameter("simple", false, "path", c.Param("path"), &path)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter path: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params AdminResetApiParams
+
+	// ------------- Optional query parameter "method" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "method", c.Request.URL.Query(), &params.Method)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter method: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.AdminResetApi(c, path, params)
+}
+
+// AdminConfigureApi operation middleware
+func (siw *ServerInterfaceWrapper) AdminConfigureApi(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "path" -------------
+	var path string
+
+	err = runtime.BindStyledParameter("simple", false, "path", c.Param("path"), &path)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter path: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params AdminConfigureApiParams
+
+	// ------------- Optional query parameter "method" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "method", c.Request.URL.Query(), &params.Method)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter method: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.AdminConfigureApi(c, path, params)
+}
+
+// GetLogLevels operation middleware
+func (siw *ServerInterfaceWrapper) GetLogLevels(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetLogLevels(c)
+}
+
+// SetLogLevels operation middleware
+func (siw *ServerInterfaceWrapper) SetLogLevels(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.SetLogLevels(c)
+}
+
+// GetReloadStatus operation middleware
+func (siw *ServerInterfaceWrapper) GetReloadStatus(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetReloadStatus(c)
+}
+
+// GetConfigSchema operation middleware
+func (siw *ServerInterfaceWrapper) GetConfigSchema(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetConfigSchema(c)
+}
+
+// GetStats operation middleware
+func (siw *ServerInterfaceWrapper) GetStats(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetStats(c)
+}
+
 // ParamsApi operation middleware
 func (siw *ServerInterfaceWrapper) ParamsApi(c *gin.Context) {
 
@@ -213,6 +727,17 @@ func (siw *ServerInterfaceWrapper) ConfigureApi(c *gin.Context) {
 		return
 	}
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ConfigureApiParams
+
+	// ------------- Optional query parameter "method" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "method", c.Request.URL.Query(), &params.Method)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter method: %w", err), http.StatusBadRequest)
+		return
+	}
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -220,7 +745,7 @@ func (siw *ServerInterfaceWrapper) ConfigureApi(c *gin.Context) {
 		}
 	}
 
-	siw.Handler.ConfigureApi(c, path)
+	siw.Handler.ConfigureApi(c, path, params)
 }
 
 // Health operation middleware
@@ -303,6 +828,14 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 	}
 
 	router.GET(options.BaseURL+"/", wrapper.Home)
+	router.GET(options.BaseURL+"/admin/apis", wrapper.AdminListApis)
+	router.DELETE(options.BaseURL+"/admin/apis/:path", wrapper.AdminResetApi)
+	router.POST(options.BaseURL+"/admin/apis/:path", wrapper.AdminConfigureApi)
+	router.GET(options.BaseURL+"/admin/log-levels", wrapper.GetLogLevels)
+	router.POST(options.BaseURL+"/admin/log-levels", wrapper.SetLogLevels)
+	router.GET(options.BaseURL+"/admin/reload", wrapper.GetReloadStatus)
+	router.GET(options.BaseURL+"/admin/schema", wrapper.GetConfigSchema)
+	router.GET(options.BaseURL+"/admin/stats", wrapper.GetStats)
 	router.GET(options.BaseURL+"/api/dynamic", wrapper.ParamsApi)
 	router.GET(options.BaseURL+"/api/dynamic/:path", wrapper.GetApi)
 	router.POST(options.BaseURL+"/api/dynamic/:path", wrapper.ConfigureApi)
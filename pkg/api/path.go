@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+type SegmentKind int
+
+// The kinds of segments by order of precedence when matching paths
+const (
+	SegmentLiteral SegmentKind = iota
+	SegmentParam
+	SegmentWildcard
+	SegmentCatchAll
+)
+
+const segmentPattern = `([a-zA-Z0-9_.-]+|\{[a-zA-Z_][a-zA-Z0-9_]*}|\*)`
+
+// rePath matches paths made of `/` separated segments which are either a literal, a `{param}` or a `*` wildcard.
+// The last segment can also be `**` to match any number of segments.
+var rePath = regexp.MustCompile(fmt.Sprintf(`^(%s(/%s)*(/\*\*)?|\*\*)$`, segmentPattern, segmentPattern))
+
+var reParam = regexp.MustCompile(`\{[a-zA-Z_][a-zA-Z0-9_]*}`)
+
+// PathSegment is a segment of the path of an api.
+// Value is the literal for SegmentLiteral and the name of the parameter for SegmentParam.
+type PathSegment struct {
+	Kind  SegmentKind
+	Value string
+}
+
+func ValidatePath(path string) error {
+	if !rePath.MatchString(path) {
+		return fmt.Errorf("'%s' doesn't match re: %s", path, rePath.String())
+	}
+	params := map[string]struct{}{}
+	for _, s := range ParsePath(path) {
+		if s.Kind != SegmentParam {
+			continue
+		}
+		if _, exists := params[s.Value]; exists {
+			return fmt.Errorf("'%s' has parameter '%s' more than once", path, s.Value)
+		}
+		params[s.Value] = struct{}{}
+	}
+	return nil
+}
+
+// CanonicalPath removes the names of the parameters of a path, paths with the same canonical path match the same requests.
+func CanonicalPath(path string) string {
+	return reParam.ReplaceAllString(path, "{}")
+}
+
+// ParsePath splits a path in segments, it expects a path which passed ValidatePath.
+func ParsePath(path string) []PathSegment {
+	var res []PathSegment
+	for _, s := range strings.Split(path, "/") {
+		switch {
+		case s == "**":
+			res = append(res, PathSegment{Kind: SegmentCatchAll})
+		case s == "*":
+			res = append(res, PathSegment{Kind: SegmentWildcard})
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			res = append(res, PathSegment{Kind: SegmentParam, Value: s[1 : len(s)-1]})
+		default:
+			res = append(res, PathSegment{Kind: SegmentLiteral, Value: s})
+		}
+	}
+	return res
+}
+
+// BodyData is what's accessible in the template of the body of an api.
+type BodyData struct {
+	// Path is the path of the request relative to the root of the dynamic apis
+	Path string
+	// Params are the values of the `{param}` segments of the path
+	Params map[string]string
+	// Claims are the claims of the JWT of the request when the api uses jwt auth
+	Claims map[string]any
+}
+
+// ParseBodyTemplate parses the body of an api which is a go template, missing keys are rendered as empty strings.
+func ParseBodyTemplate(body string) (*template.Template, error) {
+	return template.New("body").Option("missingkey=zero").Parse(body)
+}
+
+// ValidateBodyTemplate parses the body and renders it with an empty BodyData to catch fields which don't exist.
+// Other rendering errors are ignored as they can depend on the request (e.g. a nested claim which is missing).
+func ValidateBodyTemplate(body string) error {
+	t, err := ParseBodyTemplate(body)
+	if err != nil {
+		return err
+	}
+	if err := t.Execute(io.Discard, BodyData{}); err != nil && strings.Contains(err.Error(), "can't evaluate field") {
+		return err
+	}
+	return nil
+}
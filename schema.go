@@ -0,0 +1,25 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"github.com/lahabana/api-play/pkg/api"
+	"os"
+)
+
+//go:embed openapi.yaml
+var openapiSpec []byte
+
+// schemaCommand prints the JSON schema of the config file.
+func schemaCommand(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	_ = fs.Parse(args)
+	schema, err := api.ConfigJSONSchema(openapiSpec)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to build schema: %s\n", err)
+		return 1
+	}
+	writeJSON(os.Stdout, schema)
+	return 0
+}
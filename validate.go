@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/lahabana/api-play/internal/reload"
+	"github.com/lahabana/api-play/internal/server"
+	"github.com/lahabana/api-play/pkg/api"
+	"io"
+	"os"
+)
+
+type validateOutput struct {
+	Source string                  `json:"source"`
+	Valid  bool                    `json:"valid"`
+	Errors []api.InvalidParameters `json:"errors"`
+}
+
+// validateCommand checks a config the same way the server does when loading it and exits non-zero if it's invalid.
+func validateCommand(args []string) int {
+	var configFile, configDir, output string
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.StringVar(&configFile, "config-file", "", "A yaml config of the apis to validate")
+	fs.StringVar(&configDir, "config-dir", "", "A directory of yaml or json configs of the apis to validate")
+	fs.StringVar(&output, "output", "text", "The output format (options: text,json)")
+	_ = fs.Parse(args)
+	if (configFile == "") == (configDir == "") {
+		_, _ = fmt.Fprintln(os.Stderr, "exactly one of config-file and config-dir must be set")
+		return 2
+	}
+	if output != "text" && output != "json" {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid output: %s valid outputs: 'text' and 'json'\n", output)
+		return 2
+	}
+	out := validateOutput{Source: configFile, Errors: []api.InvalidParameters{}}
+	var apis api.ParamsAPI
+	var err error
+	if configFile != "" {
+		apis, err = reload.LoadFile(configFile)
+	} else {
+		out.Source = configDir
+		apis, err = reload.LoadDir(configDir)
+	}
+	if err == nil {
+		apis.Normalize()
+		err = apis.Validate()
+	}
+	if err == nil {
+		err = server.ValidateKeys(apis)
+	}
+	if err != nil {
+		if valErrors := api.InvalidParametersFromError(err); valErrors != nil {
+			out.Errors = *valErrors
+		} else {
+			out.Errors = append(out.Errors, api.InvalidParameters{Reason: err.Error()})
+		}
+	}
+	out.Valid = len(out.Errors) == 0
+	if output == "json" {
+		writeJSON(os.Stdout, out)
+	} else {
+		writeValidateText(os.Stdout, out)
+	}
+	if !out.Valid {
+		return 1
+	}
+	return 0
+}
+
+func writeValidateText(w io.Writer, out validateOutput) {
+	if out.Valid {
+		_, _ = fmt.Fprintf(w, "%s is valid\n", out.Source)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%s is invalid:\n", out.Source)
+	for _, e := range out.Errors {
+		if e.Field == "" {
+			_, _ = fmt.Fprintf(w, "  %s\n", e.Reason)
+		} else {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", e.Field, e.Reason)
+		}
+	}
+}
+
+func writeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}